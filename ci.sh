@@ -2,6 +2,9 @@
 
 set -e
 
+# the dependencies are vendored, there is no go.mod
+export GO111MODULE=off
+
 release-build() {
   local tag=$1
   rm -rf build
@@ -11,7 +14,7 @@ release-build() {
       local fname=build/nexus-cli-${os}-${arch}-$tag
       [ "$os" == "windows" ] && fname="${fname}.exe"
       echo "building $fname"
-      GOOS=${os} GOARCH=${arch} go build -o ${fname} main.go
+      GOOS=${os} GOARCH=${arch} go build -o ${fname}
     done
   done
   gzip build/*
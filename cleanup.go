@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hanjos/nexus"
+	"github.com/hanjos/nexus/search"
+	"github.com/thomasf/lg"
+)
+
+// Age is a duration flag which also accepts days and weeks, e.g. 90d or 2w.
+type Age time.Duration
+
+// UnmarshalFlag implements the flags.Unmarshaler interface.
+func (a *Age) UnmarshalFlag(value string) error {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(value, suffix) {
+			n, err := strconv.Atoi(strings.TrimSuffix(value, suffix))
+			if err != nil {
+				return fmt.Errorf("invalid age: %s", value)
+			}
+			*a = Age(time.Duration(n) * unit)
+			return nil
+		}
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid age: %s", value)
+	}
+	*a = Age(d)
+	return nil
+}
+
+type CleanupCommand struct {
+	Keep          int  `long:"keep" description:"Keep the newest N versions by semver"`
+	OlderThan     Age  `long:"older-than" description:"Only remove versions not changed for this long, e.g. 90d, 2w or 36h"`
+	SnapshotsOnly bool `long:"snapshots-only" description:"Only consider snapshot versions"`
+	Yes           bool `long:"yes" short:"y" description:"Do not ask for confirmation"`
+	DryRun        bool `long:"dry-run" short:"n" description:"Only print the plan"`
+}
+
+// cleanupVersion is all artifacts of one version.
+type cleanupVersion struct {
+	Version     string
+	Artifacts   []Artifact
+	LastChanged time.Time
+	Remove      bool
+}
+
+func (s *CleanupCommand) Execute(args []string) error {
+	ctx := rootContext
+	if len(args) < 1 {
+		return usageErrorf("usage: cleanup g:a@repository")
+	}
+	if s.Keep <= 0 && s.OlderThan <= 0 {
+		return usageErrorf("give --keep and/or --older-than")
+	}
+	ga, repositoryID := args[0], ""
+	if pos := strings.LastIndex(ga, "@"); pos != -1 {
+		ga, repositoryID = ga[:pos], ga[pos+1:]
+	}
+	parts := strings.Split(ga, ":")
+	if len(parts) != 2 || repositoryID == "" {
+		return usageErrorf("expected g:a@repository, got %s", args[0])
+	}
+
+	creds, err := newCredentials()
+	if err != nil {
+		return err
+	}
+	n, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	arts, err := n.ArtifactsContext(ctx, search.InRepository{
+		RepositoryID: repositoryID,
+		Criteria:     search.ByCoordinates{GroupID: parts[0], ArtifactID: parts[1]},
+	})
+	if err != nil {
+		return err
+	}
+
+	versions, err := s.plan(ctx, n, parts[0], parts[1], arts)
+	if err != nil {
+		return err
+	}
+
+	var remove int
+	for _, v := range versions {
+		action := "keep  "
+		if v.Remove {
+			action = "delete"
+			remove++
+		}
+		changed := "-"
+		if !v.LastChanged.IsZero() {
+			changed = v.LastChanged.Format(time.RFC3339)
+		}
+		fmt.Printf("%s %s:%s:%s@%s (last changed %s)\n", action, parts[0], parts[1], v.Version, repositoryID, changed)
+	}
+	if s.DryRun || remove == 0 {
+		return nil
+	}
+
+	if !s.Yes {
+		ok, err := confirm(fmt.Sprintf("Delete %d versions?", remove))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("aborted")
+		}
+	}
+	for _, v := range versions {
+		if !v.Remove {
+			continue
+		}
+		if err := deleteVersion(ctx, n, repositoryID, parts[0], parts[1], v.Version, creds); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "deleted %d versions\n", remove)
+	return nil
+}
+
+// plan groups the artifacts of groupID:artifactID by version, newest first,
+// and marks the ones to remove. Other artifacts are left out, as the search
+// of Nexus 2 also matches similar coordinates like those of lib-api for lib.
+func (s *CleanupCommand) plan(ctx context.Context, n contextClient, groupID, artifactID string, arts []*nexus.Artifact) ([]*cleanupVersion, error) {
+	var artifacts []Artifact
+	for _, a := range arts {
+		if a.GroupID != groupID || a.ArtifactID != artifactID {
+			lg.V(5).Infoln("skipping", a, ", not", groupID+":"+artifactID)
+			continue
+		}
+		art, err := newArtifact(a)
+		if err != nil {
+			return nil, err
+		}
+		if art.v == nil {
+			lg.Warningln("could not parse version from", art, ", keeping it")
+			continue
+		}
+		if s.SnapshotsOnly && !strings.HasSuffix(a.Version, "SNAPSHOT") {
+			continue
+		}
+		artifacts = append(artifacts, art)
+	}
+	sort.Sort(sort.Reverse(BySemver(artifacts)))
+
+	byVersion := make(map[string]*cleanupVersion)
+	var versions []*cleanupVersion
+	for _, a := range artifacts {
+		v, ok := byVersion[a.Version]
+		if !ok {
+			v = &cleanupVersion{Version: a.Version}
+			byVersion[a.Version] = v
+			versions = append(versions, v)
+		}
+		v.Artifacts = append(v.Artifacts, a)
+	}
+
+	cutoff := time.Now().Add(-time.Duration(s.OlderThan))
+	for i, v := range versions {
+		if i < s.Keep {
+			continue
+		}
+		v.Remove = true
+		if s.OlderThan <= 0 {
+			continue
+		}
+		known := true
+		for _, a := range v.Artifacts {
+			info, err := infoOf(ctx, n, a)
+			if err != nil {
+				return nil, err
+			}
+			if info.LastChanged.IsZero() {
+				known = false
+			}
+			if info.LastChanged.After(v.LastChanged) {
+				v.LastChanged = info.LastChanged
+			}
+		}
+		// a version of unknown age might be newer than the cutoff
+		if !known {
+			lg.Warningln("last change of", v.Version, "is unknown, keeping it")
+			v.LastChanged = time.Time{}
+			v.Remove = false
+			continue
+		}
+		v.Remove = v.LastChanged.Before(cutoff)
+	}
+	return versions, nil
+}
+
+var cleanupCommand CleanupCommand
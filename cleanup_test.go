@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hanjos/nexus"
+	"github.com/hanjos/nexus/search"
+)
+
+// lastChangedClient is a contextClient which only knows the last change of
+// each version, versions missing from it have none.
+type lastChangedClient map[string]time.Time
+
+func (c lastChangedClient) Artifacts(criteria search.Criteria) ([]*nexus.Artifact, error) {
+	return nil, nil
+}
+
+func (c lastChangedClient) Repositories() ([]*nexus.Repository, error) {
+	return nil, nil
+}
+
+func (c lastChangedClient) InfoOf(a *nexus.Artifact) (*nexus.ArtifactInfo, error) {
+	return &nexus.ArtifactInfo{Artifact: a, LastChanged: c[a.Version]}, nil
+}
+
+func (c lastChangedClient) ArtifactsContext(ctx context.Context, criteria search.Criteria) ([]*nexus.Artifact, error) {
+	return c.Artifacts(criteria)
+}
+
+func (c lastChangedClient) RepositoriesContext(ctx context.Context) ([]*nexus.Repository, error) {
+	return c.Repositories()
+}
+
+func (c lastChangedClient) InfoOfContext(ctx context.Context, a *nexus.Artifact) (*nexus.ArtifactInfo, error) {
+	return c.InfoOf(a)
+}
+
+func TestCleanupPlan(t *testing.T) {
+	day := 24 * time.Hour
+	ago := func(d time.Duration) time.Time { return time.Now().Add(-d) }
+	client := lastChangedClient{
+		"1.0.0":          ago(400 * day),
+		"1.1.0":          ago(200 * day),
+		"1.2.0":          ago(100 * day),
+		"2.0.0":          ago(10 * day),
+		"2.1.0-SNAPSHOT": ago(1 * day),
+		// "1.3.0" has no last change
+	}
+	versions := []string{"1.0.0", "1.1.0", "1.2.0", "1.3.0", "2.0.0", "2.1.0-SNAPSHOT", "nightly"}
+
+	tests := []struct {
+		name string
+		cmd  CleanupCommand
+		want []string
+	}{
+		{
+			name: "keep",
+			cmd:  CleanupCommand{Keep: 2},
+			want: []string{"keep 2.1.0-SNAPSHOT", "keep 2.0.0", "delete 1.3.0", "delete 1.2.0", "delete 1.1.0", "delete 1.0.0"},
+		},
+		{
+			name: "older than",
+			cmd:  CleanupCommand{OlderThan: Age(150 * day)},
+			want: []string{"keep 2.1.0-SNAPSHOT", "keep 2.0.0", "keep 1.3.0", "keep 1.2.0", "delete 1.1.0", "delete 1.0.0"},
+		},
+		{
+			name: "keep and older than",
+			cmd:  CleanupCommand{Keep: 1, OlderThan: Age(50 * day)},
+			want: []string{"keep 2.1.0-SNAPSHOT", "keep 2.0.0", "keep 1.3.0", "delete 1.2.0", "delete 1.1.0", "delete 1.0.0"},
+		},
+		{
+			name: "snapshots only",
+			cmd:  CleanupCommand{SnapshotsOnly: true},
+			want: []string{"delete 2.1.0-SNAPSHOT"},
+		},
+	}
+	for _, tt := range tests {
+		var arts []*nexus.Artifact
+		for _, v := range versions {
+			for _, ext := range []string{"jar", "pom"} {
+				arts = append(arts, &nexus.Artifact{GroupID: "g", ArtifactID: "a", Version: v, Extension: ext, RepositoryID: "r"})
+			}
+		}
+		// inexact search matches, which must not take up --keep slots
+		arts = append(arts,
+			&nexus.Artifact{GroupID: "g", ArtifactID: "a-api", Version: "3.0.0", Extension: "jar", RepositoryID: "r"},
+			&nexus.Artifact{GroupID: "g", ArtifactID: "a-api", Version: "2.0.0", Extension: "jar", RepositoryID: "r"},
+			&nexus.Artifact{GroupID: "g.internal", ArtifactID: "a", Version: "2.5.0", Extension: "jar", RepositoryID: "r"},
+		)
+		plan, err := tt.cmd.plan(context.Background(), client, "g", "a", arts)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var got []string
+		for _, v := range plan {
+			if len(v.Artifacts) != 2 {
+				t.Errorf("%s: %s has %d artifacts, want 2", tt.name, v.Version, len(v.Artifacts))
+			}
+			action := "keep"
+			if v.Remove {
+				action = "delete"
+			}
+			got = append(got, fmt.Sprintf("%s %s", action, v.Version))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
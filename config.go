@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hanjos/nexus/credentials"
+	"github.com/jessevdk/go-flags"
+	"github.com/thomasf/lg"
+)
+
+// configPaths returns the config files which are looked for when none is
+// given, in order of preference.
+func configPaths() []string {
+	paths := []string{"settings.ini"}
+	home, _ := os.UserHomeDir()
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" && home != "" {
+		xdg = filepath.Join(home, ".config")
+	}
+	if xdg != "" {
+		paths = append(paths, filepath.Join(xdg, "nexus-cli", "config.ini"))
+	}
+	if home != "" {
+		paths = append(paths, filepath.Join(home, ".nexus-cli.ini"))
+	}
+	return paths
+}
+
+// findConfig returns the config file to read. A file given with --config or
+// $NEXUS_CLI_CONFIG has to exist, otherwise the first existing file of
+// configPaths is used. An empty path means there is no config file.
+func findConfig(args []string) (string, error) {
+	path := argValue(args, "--config")
+	if path == "" {
+		path = os.Getenv("NEXUS_CLI_CONFIG")
+	}
+	if path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("config file: %v", err)
+		}
+		return path, nil
+	}
+	for _, path := range configPaths() {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", nil
+}
+
+// argValue returns the value of a long option in the command line
+// arguments, it is needed for options which are used before the command line
+// is parsed.
+func argValue(args []string, name string) string {
+	for i, arg := range args {
+		switch {
+		case arg == "--":
+			return ""
+		case arg == name && i+1 < len(args):
+			return args[i+1]
+		case strings.HasPrefix(arg, name+"="):
+			return strings.TrimPrefix(arg, name+"=")
+		}
+	}
+	return ""
+}
+
+// configFile is a config file split into its main part and its
+// [profile.NAME] sections. The lines belonging to other parts are blanked
+// out so that errors point at the right line of the file.
+type configFile struct {
+	Path     string
+	Main     string
+	Profiles map[string]string
+	Names    []string // profile names in file order
+}
+
+// config is the config file in use, nil if there is none.
+var config *configFile
+
+func readConfig(path string) (*configFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(data), "\n")
+	c := &configFile{Path: path, Profiles: make(map[string]string)}
+	parts := map[string][]string{"": make([]string, len(lines))}
+	current := ""
+	for i, line := range lines {
+		l := strings.TrimSpace(line)
+		if strings.HasPrefix(l, "[") && strings.HasSuffix(l, "]") {
+			current = ""
+			name := strings.TrimSpace(l[1 : len(l)-1])
+			if strings.HasPrefix(name, "profile.") {
+				current = strings.TrimPrefix(name, "profile.")
+				if current == "" {
+					return nil, fmt.Errorf("%s:%d: missing profile name", path, i+1)
+				}
+				if _, ok := parts[current]; ok {
+					return nil, fmt.Errorf("%s:%d: duplicate profile %s", path, i+1, current)
+				}
+				parts[current] = make([]string, len(lines))
+				c.Names = append(c.Names, current)
+				continue
+			}
+		}
+		parts[current][i] = line
+	}
+	c.Main = strings.Join(parts[""], "\n")
+	for _, name := range c.Names {
+		c.Profiles[name] = strings.Join(parts[name], "\n")
+	}
+	return c, nil
+}
+
+// parseProfile reads a profile into the options of p.
+func (c *configFile) parseProfile(p *flags.Parser, profile string) error {
+	part, ok := c.Profiles[profile]
+	if !ok {
+		return fmt.Errorf("no profile %s in %s", profile, c.Path)
+	}
+	return c.parsePart(p, part)
+}
+
+func (c *configFile) parsePart(p *flags.Parser, part string) error {
+	err := flags.NewIniParser(p).Parse(strings.NewReader(part))
+	if e, ok := err.(*flags.IniError); ok {
+		e.File = c.Path
+	}
+	return err
+}
+
+// envConfig returns the options set in the environment in ini format. The
+// env tags alone only provide defaults for go-flags, which would lose against
+// the config file, so they are read as the last part of the config instead.
+func envConfig() string {
+	var b bytes.Buffer
+	t := reflect.TypeOf(options)
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag
+		key, name := tag.Get("env"), tag.Get("ini-name")
+		if key == "" || name == "" {
+			continue
+		}
+		if value, ok := os.LookupEnv(key); ok {
+			fmt.Fprintf(&b, "%s = %s\n", name, strconv.Quote(value))
+		}
+	}
+	return b.String()
+}
+
+// loadConfig reads the config file, the selected profile and the environment
+// into the options of p. Options read this way count as set so their defaults
+// are not applied, command line flags still override them. The precedence is
+// flags > environment > profile > config file.
+func loadConfig(p *flags.Parser, args []string) error {
+	path, err := findConfig(args)
+	if err != nil {
+		return err
+	}
+	profile := argValue(args, "--profile")
+	if profile == "" {
+		profile = os.Getenv("NEXUS_CLI_PROFILE")
+	}
+	if path == "" {
+		lg.V(5).Infoln("no config file found in", strings.Join(configPaths(), ", "))
+		if profile != "" {
+			return fmt.Errorf("no config file to read profile %s from", profile)
+		}
+	} else {
+		lg.V(5).Infoln("reading config from", path)
+		config, err = readConfig(path)
+		if err != nil {
+			return err
+		}
+		if err := config.parsePart(p, config.Main); err != nil {
+			return err
+		}
+		// default-profile is known after reading the main part
+		if profile == "" {
+			profile = options.Profile
+		}
+		options.Profile = profile
+		if profile != "" {
+			if err := config.parseProfile(p, profile); err != nil {
+				return err
+			}
+		}
+	}
+	err = flags.NewIniParser(p).Parse(strings.NewReader(envConfig()))
+	if e, ok := err.(*flags.IniError); ok {
+		return fmt.Errorf("environment: %s", e.Message)
+	}
+	return err
+}
+
+type ProfilesCommand struct{}
+
+func (s *ProfilesCommand) Execute(args []string) error {
+	if config == nil {
+		return fmt.Errorf("no config file found in %s", strings.Join(configPaths(), ", "))
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, name := range config.Names {
+		// each profile on top of the main part, like when it is used
+		var o Options
+		p := flags.NewParser(&o, flags.IgnoreUnknown)
+		if err := config.parsePart(p, config.Main); err != nil {
+			return err
+		}
+		if err := config.parseProfile(p, name); err != nil {
+			return err
+		}
+		active := " "
+		if name == options.Profile {
+			active = "*"
+		}
+		fmt.Fprintf(w, "%s %s\t%s\t%v\n", active, name, o.Host, credentials.BasicAuth(o.User, o.Password))
+	}
+	return w.Flush()
+}
+
+var profilesCommand ProfilesCommand
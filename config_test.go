@@ -0,0 +1,224 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// setupConfig gives the test an empty home, config and working directory and
+// an environment without NEXUS_CLI_ variables.
+func setupConfig(t *testing.T) string {
+	dir := t.TempDir()
+	for _, d := range []string{"home", "xdg", "work"} {
+		if err := os.Mkdir(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("HOME", filepath.Join(dir, "home"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+	for _, key := range []string{
+		"NEXUS_CLI_USER", "NEXUS_CLI_PASSWORD", "NEXUS_CLI_HOST", "NEXUS_CLI_RETRIES",
+		"NEXUS_CLI_TIMEOUT", "NEXUS_CLI_PROFILE", "NEXUS_CLI_CONFIG",
+	} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(filepath.Join(dir, "work")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return dir
+}
+
+func writeFile(t *testing.T, path, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindConfig(t *testing.T) {
+	dir := setupConfig(t)
+	local := filepath.Join(dir, "work", "settings.ini")
+	xdg := filepath.Join(dir, "xdg", "nexus-cli", "config.ini")
+	home := filepath.Join(dir, "home", ".nexus-cli.ini")
+	given := filepath.Join(dir, "given.ini")
+	for _, path := range []string{local, xdg, home, given} {
+		writeFile(t, path, "")
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		env    string
+		remove string // removed before the lookup
+		want   string
+	}{
+		{name: "flag", args: []string{"--config", given}, env: home, want: given},
+		{name: "flag with =", args: []string{"--config=" + given}, want: given},
+		{name: "env", env: given, want: given},
+		{name: "working directory", want: "settings.ini"},
+		{name: "xdg", remove: local, want: xdg},
+		{name: "home", remove: xdg, want: home},
+		{name: "none", remove: home, want: ""},
+	}
+	for _, tt := range tests {
+		if tt.remove != "" {
+			os.Remove(tt.remove)
+		}
+		os.Unsetenv("NEXUS_CLI_CONFIG")
+		if tt.env != "" {
+			os.Setenv("NEXUS_CLI_CONFIG", tt.env)
+		}
+		got, err := findConfig(tt.args)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	os.Unsetenv("NEXUS_CLI_CONFIG")
+	if _, err := findConfig([]string{"--config", filepath.Join(dir, "missing.ini")}); err == nil {
+		t.Error("missing --config file: no error")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	const file = `host = http://main
+username = main
+retries = 5
+
+[profile.staging]
+host = http://staging
+
+[profile.prod]
+host = http://prod
+username = deployer
+`
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		args    []string
+		want    Options
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: Options{Retries: 3, Timeout: 2 * time.Minute},
+		},
+		{
+			name: "file",
+			file: file,
+			want: Options{Host: "http://main", User: "main", Retries: 5, Timeout: 2 * time.Minute},
+		},
+		{
+			name: "profile flag",
+			file: file,
+			args: []string{"--profile", "prod"},
+			want: Options{Host: "http://prod", User: "deployer", Retries: 5, Timeout: 2 * time.Minute, Profile: "prod"},
+		},
+		{
+			name: "default profile",
+			file: "default-profile = staging\n" + file,
+			want: Options{Host: "http://staging", User: "main", Retries: 5, Timeout: 2 * time.Minute, Profile: "staging"},
+		},
+		{
+			name: "env profile over default profile",
+			file: "default-profile = staging\n" + file,
+			env:  map[string]string{"NEXUS_CLI_PROFILE": "prod"},
+			want: Options{Host: "http://prod", User: "deployer", Retries: 5, Timeout: 2 * time.Minute, Profile: "prod"},
+		},
+		{
+			name: "profile flag over env profile",
+			file: file,
+			env:  map[string]string{"NEXUS_CLI_PROFILE": "prod"},
+			args: []string{"--profile=staging"},
+			want: Options{Host: "http://staging", User: "main", Retries: 5, Timeout: 2 * time.Minute, Profile: "staging"},
+		},
+		{
+			name: "env over profile",
+			file: file,
+			env:  map[string]string{"NEXUS_CLI_HOST": "http://env", "NEXUS_CLI_RETRIES": "0"},
+			args: []string{"--profile", "prod"},
+			want: Options{Host: "http://env", User: "deployer", Retries: 0, Timeout: 2 * time.Minute, Profile: "prod"},
+		},
+		{
+			name: "flags over env",
+			file: file,
+			env:  map[string]string{"NEXUS_CLI_HOST": "http://env", "NEXUS_CLI_TIMEOUT": "10s"},
+			args: []string{"--host", "http://flag", "--retries", "1"},
+			want: Options{Host: "http://flag", User: "main", Retries: 1, Timeout: 10 * time.Second},
+		},
+		{
+			name: "env with spaces and quotes",
+			env: map[string]string{
+				"NEXUS_CLI_USER":     "  John Doe ",
+				"NEXUS_CLI_PASSWORD": `pa"ss 'wo\rd`,
+			},
+			want: Options{User: "  John Doe ", Password: `pa"ss 'wo\rd`, Retries: 3, Timeout: 2 * time.Minute},
+		},
+		{
+			name:    "unknown profile",
+			file:    file,
+			args:    []string{"--profile", "dev"},
+			wantErr: true,
+		},
+		{
+			name:    "profile without a config file",
+			env:     map[string]string{"NEXUS_CLI_PROFILE": "prod"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid env value",
+			env:     map[string]string{"NEXUS_CLI_RETRIES": "many"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		dir := setupConfig(t)
+		if tt.file != "" {
+			writeFile(t, filepath.Join(dir, "home", ".nexus-cli.ini"), tt.file)
+		}
+		for k, v := range tt.env {
+			os.Setenv(k, v)
+		}
+
+		options, config = Options{}, nil
+		p := flags.NewParser(&options, flags.None)
+		err := loadConfig(p, tt.args)
+		if err == nil {
+			_, err = p.ParseArgs(tt.args)
+		}
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: no error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		// the defaults which are the same in every case
+		got := options
+		got.Format, got.API, got.ConnectTimeout, got.RetryMaxWait = "", "", 0, 0
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+	options, config = Options{}, nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hanjos/nexus"
+	"github.com/hanjos/nexus/credentials"
+	"github.com/hanjos/nexus/util"
+	"github.com/thomasf/lg"
+)
+
+// parseArtifact parses g:a:v, g:a:e:v or g:a:e:c:v with an optional
+// @repository suffix.
+func parseArtifact(gav string) (*nexus.Artifact, error) {
+	var a nexus.Artifact
+	if pos := strings.LastIndex(gav, "@"); pos != -1 {
+		a.RepositoryID = gav[pos+1:]
+		gav = gav[:pos]
+	}
+	parts := strings.Split(gav, ":")
+	switch len(parts) {
+	case 3:
+		a.GroupID, a.ArtifactID, a.Version = parts[0], parts[1], parts[2]
+	case 4:
+		a.GroupID, a.ArtifactID, a.Extension, a.Version = parts[0], parts[1], parts[2], parts[3]
+	case 5:
+		a.GroupID, a.ArtifactID, a.Extension, a.Classifier, a.Version = parts[0], parts[1], parts[2], parts[3], parts[4]
+	default:
+		return nil, usageErrorf("invalid artifact coordinates: %s", gav)
+	}
+	for _, v := range parts {
+		if v == "" {
+			return nil, usageErrorf("invalid artifact coordinates: %s", gav)
+		}
+	}
+	return &a, nil
+}
+
+// mavenPath returns the path of an artifact in a maven 2 layout repository,
+// e.g. org/example/lib/1.0/lib-1.0-sources.jar.
+func mavenPath(a *nexus.Artifact) string {
+	name := a.ArtifactID + "-" + a.Version
+	if a.Classifier != "" {
+		name += "-" + a.Classifier
+	}
+	return strings.Join([]string{
+		strings.Replace(a.GroupID, ".", "/", -1),
+		a.ArtifactID,
+		a.Version,
+		name + "." + a.Extension,
+	}, "/")
+}
+
+// contentURL returns the URL of a file in a repository, as used for
+// downloading, deploying and deleting.
+func contentURL(n contextClient, repositoryID, path string) (string, error) {
+	switch n := n.(type) {
+	case *Nexus2x:
+		return util.CleanSlashes(n.URL + "/content/repositories/" + repositoryID + "/" + path)
+	case *Nexus3x:
+		return util.CleanSlashes(n.URL + "/repository/" + repositoryID + "/" + path)
+	}
+	return "", errors.New("unsupported nexus client")
+}
+
+// doRequest sends a request with the given credentials, turning error
+// responses into errors like the nexus package does. The caller must close
+// the body of the returned response.
+func doRequest(ctx context.Context, method, url string, body io.Reader, size int64, creds credentials.Credentials) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	creds.Sign(req)
+
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	status := resp.StatusCode
+	switch {
+	case status == http.StatusUnauthorized:
+		resp.Body.Close()
+		return nil, &credentials.Error{URL: url, Credentials: creds}
+	case 400 <= status && status < 600:
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		e := nexus.Error{
+			URL:        url,
+			StatusCode: status,
+			Status:     resp.Status,
+			Message:    fmt.Sprintf("Error (%v) from %v", resp.Status, url),
+		}
+		if m := strings.TrimSpace(string(msg)); m != "" && !strings.HasPrefix(m, "<") {
+			e.Message += ": " + m
+		}
+		return nil, e
+	}
+	return resp, nil
+}
+
+// exists returns true if url can be fetched.
+func exists(ctx context.Context, url string, creds credentials.Credentials) (bool, error) {
+	resp, err := doRequest(ctx, "HEAD", url, nil, 0, creds)
+	if err != nil {
+		if e, ok := err.(nexus.Error); ok && e.StatusCode == http.StatusNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+// repositoryPathOf returns the path of an artifact inside its repository,
+// resolving snapshots to the stored file.
+func repositoryPathOf(ctx context.Context, n contextClient, a *nexus.Artifact, creds credentials.Credentials) (string, error) {
+	switch n := n.(type) {
+	case *Nexus2x:
+		// the same resolve call the nexus package uses internally
+		query := url.Values{
+			"g": {a.GroupID},
+			"a": {a.ArtifactID},
+			"v": {a.Version},
+			"e": {a.Extension},
+			"c": {a.Classifier},
+			"r": {a.RepositoryID},
+		}
+		u, err := util.CleanSlashes(n.URL + "/service/local/artifact/maven/resolve?" + query.Encode())
+		if err != nil {
+			return "", err
+		}
+		resp, err := doRequest(ctx, "GET", u, nil, 0, creds)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		var payload struct {
+			RepositoryPath string `xml:"data>repositoryPath"`
+		}
+		if err := xml.NewDecoder(resp.Body).Decode(&payload); err != nil {
+			return "", err
+		}
+		return strings.TrimPrefix(payload.RepositoryPath, "/"), nil
+	case *Nexus3x:
+		info, err := n.InfoOfContext(ctx, a)
+		if err != nil {
+			return "", err
+		}
+		prefix, err := contentURL(n, a.RepositoryID, "")
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasPrefix(info.URL, prefix) {
+			return "", fmt.Errorf("unexpected download URL %s", info.URL)
+		}
+		return strings.TrimPrefix(info.URL, prefix), nil
+	}
+	return "", errors.New("unsupported nexus client")
+}
+
+// deleteURL returns the URL which removes a file from a repository.
+func deleteURL(n contextClient, repositoryID, path string) (string, error) {
+	switch n := n.(type) {
+	case *Nexus2x:
+		return util.CleanSlashes(n.URL + "/service/local/repositories/" + repositoryID + "/content/" + path)
+	case *Nexus3x:
+		return contentURL(n, repositoryID, path)
+	}
+	return "", errors.New("unsupported nexus client")
+}
+
+// deleteVersion removes all files of a version from a repository, including
+// every build of a snapshot.
+func deleteVersion(ctx context.Context, n contextClient, repositoryID, groupID, artifactID, version string, creds credentials.Credentials) error {
+	switch n := n.(type) {
+	case *Nexus2x:
+		dir := strings.Join([]string{strings.Replace(groupID, ".", "/", -1), artifactID, version}, "/") + "/"
+		url, err := deleteURL(n, repositoryID, dir)
+		if err != nil {
+			return err
+		}
+		lg.Infoln("deleting", url)
+		resp, err := doRequest(ctx, "DELETE", url, nil, 0, creds)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	case *Nexus3x:
+		ids, err := n.componentIDs(ctx, repositoryID, groupID, artifactID, version)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			url, err := util.CleanSlashes(n.URL + "/service/rest/v1/components/" + id)
+			if err != nil {
+				return err
+			}
+			lg.Infoln("deleting", url)
+			resp, err := doRequest(ctx, "DELETE", url, nil, 0, creds)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+		}
+		return nil
+	}
+	return errors.New("unsupported nexus client")
+}
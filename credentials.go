@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hanjos/nexus/credentials"
+	"github.com/thomasf/lg"
+)
+
+// netrcMachine is a machine or default entry of a .netrc file.
+type netrcMachine struct {
+	Name     string // empty for the default entry
+	Login    string
+	Password string
+}
+
+// netrc signs requests with the login and password of the .netrc entry
+// matching the request host, falling back to the default entry. Requests to
+// other hosts are not signed. It also implements the fmt.Stringer interface.
+type netrc struct {
+	Path     string
+	Machines []netrcMachine
+}
+
+// netrcPath returns $NETRC or ~/.netrc.
+func netrcPath() (string, error) {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".netrc"), nil
+}
+
+// newNetrc reads the .netrc file at path.
+func newNetrc(path string) (credentials.Credentials, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if fi, err := f.Stat(); err == nil && fi.Mode().Perm()&077 != 0 {
+		lg.Warningln(path, "is readable by others, consider chmod 600")
+	}
+
+	type token struct {
+		text string
+		line int
+	}
+	var tokens []token
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		for _, field := range strings.Fields(scanner.Text()) {
+			tokens = append(tokens, token{field, len(lines)})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	n := &netrc{Path: path}
+	var m *netrcMachine
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch t.text {
+		case "default":
+			n.Machines = append(n.Machines, netrcMachine{})
+			m = &n.Machines[len(n.Machines)-1]
+			continue
+		case "macdef":
+			// a macro definition runs until the next empty line
+			end := t.line
+			for end < len(lines) && strings.TrimSpace(lines[end]) != "" {
+				end++
+			}
+			for i+1 < len(tokens) && tokens[i+1].line <= end {
+				i++
+			}
+			continue
+		case "machine", "login", "password", "account":
+		default:
+			return nil, fmt.Errorf("%s:%d: unexpected %q", path, t.line, t.text)
+		}
+		if i+1 == len(tokens) {
+			return nil, fmt.Errorf("%s:%d: missing value for %s", path, t.line, t.text)
+		}
+		i++
+		value := tokens[i].text
+		if t.text == "machine" {
+			n.Machines = append(n.Machines, netrcMachine{Name: value})
+			m = &n.Machines[len(n.Machines)-1]
+			continue
+		}
+		if m == nil {
+			return nil, fmt.Errorf("%s:%d: %s outside of a machine entry", path, t.line, t.text)
+		}
+		switch t.text {
+		case "login":
+			m.Login = value
+		case "password":
+			m.Password = value
+		}
+	}
+	return n, nil
+}
+
+// lookup returns the entry of the first matching host, or the default entry
+// if there is none.
+func (n *netrc) lookup(hosts ...string) *netrcMachine {
+	for _, host := range hosts {
+		for i, m := range n.Machines {
+			if m.Name != "" && strings.EqualFold(m.Name, host) {
+				return &n.Machines[i]
+			}
+		}
+	}
+	for i, m := range n.Machines {
+		if m.Name == "" {
+			return &n.Machines[i]
+		}
+	}
+	return nil
+}
+
+func (n *netrc) Sign(request *http.Request) {
+	if request == nil {
+		return
+	}
+	// entries are usually host names but may include the port
+	m := n.lookup(request.URL.Host, request.URL.Hostname())
+	if m == nil {
+		credentials.None.Sign(request)
+		return
+	}
+	credentials.BasicAuth(m.Login, m.Password).Sign(request)
+}
+
+func (n *netrc) String() string {
+	return "Netrc(" + n.Path + ")"
+}
+
+// bearer signs requests with a bearer token. It also implements the
+// fmt.Stringer interface.
+type bearer string
+
+func (token bearer) Sign(request *http.Request) {
+	if request == nil {
+		return
+	}
+	request.Header.Set("Authorization", "Bearer "+string(token))
+}
+
+func (token bearer) String() string {
+	return "Bearer(***)"
+}
+
+// header signs requests by setting a header, e.g. the token expected by an
+// authenticating proxy. It also implements the fmt.Stringer interface.
+type header struct {
+	Name  string
+	Value string
+}
+
+// parseHeader parses a header given as "Name: value".
+func parseHeader(s string) (header, error) {
+	pos := strings.Index(s, ":")
+	if pos < 1 {
+		return header{}, fmt.Errorf("expected a header as 'Name: value', got %q", s)
+	}
+	return header{Name: strings.TrimSpace(s[:pos]), Value: strings.TrimSpace(s[pos+1:])}, nil
+}
+
+func (h header) Sign(request *http.Request) {
+	if request == nil {
+		return
+	}
+	request.Header.Set(h.Name, h.Value)
+}
+
+func (h header) String() string {
+	return "Header(" + h.Name + ", ***)"
+}
+
+// combined signs requests with each of its credentials in turn. It also
+// implements the fmt.Stringer interface.
+type combined []credentials.Credentials
+
+func (c combined) Sign(request *http.Request) {
+	for _, creds := range c {
+		creds.Sign(request)
+	}
+}
+
+func (c combined) String() string {
+	s := make([]string, len(c))
+	for i, creds := range c {
+		s[i] = fmt.Sprint(creds)
+	}
+	return strings.Join(s, " + ")
+}
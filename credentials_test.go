@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func writeNetrc(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), ".netrc")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNetrc(t *testing.T) {
+	path := writeNetrc(t, `machine nexus.example.com login reader password r3ad
+
+macdef init
+machine evil.example.com login macro password m4cro
+cd /pub
+
+machine nexus.example.com:8443
+	login deployer
+	password d3ploy
+	account unused
+
+default login anonymous password guest
+`)
+	creds, err := newNetrc(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url                string
+		username, password string
+	}{
+		{"https://nexus.example.com/nexus", "reader", "r3ad"},
+		{"https://NEXUS.example.com:8081/nexus", "reader", "r3ad"},
+		{"https://nexus.example.com:8443/nexus", "deployer", "d3ploy"},
+		{"https://evil.example.com/", "anonymous", "guest"},
+		{"https://other.example.com/", "anonymous", "guest"},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest("GET", tt.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		creds.Sign(req)
+		username, password, ok := req.BasicAuth()
+		if !ok || username != tt.username || password != tt.password {
+			t.Errorf("%s signed with %q/%q, want %q/%q", tt.url, username, password, tt.username, tt.password)
+		}
+	}
+}
+
+func TestNetrcWithoutDefault(t *testing.T) {
+	creds, err := newNetrc(writeNetrc(t, "machine nexus.example.com login reader password r3ad\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("GET", "https://other.example.com/", nil)
+	creds.Sign(req)
+	if _, _, ok := req.BasicAuth(); ok {
+		t.Errorf("request to an unknown host was signed")
+	}
+}
+
+func TestNetrcErrors(t *testing.T) {
+	for _, content := range []string{
+		"login reader password r3ad\n",
+		"machine nexus.example.com login\n",
+		"machine nexus.example.com user reader\n",
+	} {
+		if _, err := newNetrc(writeNetrc(t, content)); err == nil {
+			t.Errorf("parsing %q succeeded", content)
+		}
+	}
+}
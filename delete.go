@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/hanjos/nexus"
+	"github.com/hanjos/nexus/credentials"
+	"github.com/thomasf/lg"
+)
+
+type DeleteCommand struct {
+	Yes           bool `long:"yes" short:"y" description:"Do not ask for confirmation"`
+	DryRun        bool `long:"dry-run" short:"n" description:"Only print the repository paths which would be deleted"`
+	FilterOptions FilterOptions
+}
+
+func (s *DeleteCommand) Execute(args []string) error {
+	ctx := rootContext
+	if len(args) < 1 {
+		return usageErrorf("missing artifact coordinates")
+	}
+	creds, err := newCredentials()
+	if err != nil {
+		return err
+	}
+	n, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	// deleting a whole version includes its POM
+	filter := s.FilterOptions
+	wholeVersion := strings.Count(strings.SplitN(args[0], "@", 2)[0], ":") == 2
+	if wholeVersion {
+		filter.POM = true
+	}
+	artifacts, err := resolve(ctx, n, args[0], &filter)
+	if err != nil {
+		return err
+	}
+	if wholeVersion {
+		return s.deleteVersions(ctx, n, artifacts, creds)
+	}
+
+	paths := make([]string, len(artifacts))
+	for i, v := range artifacts {
+		paths[i], err = repositoryPathOf(ctx, n, v.Artifact, creds)
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.DryRun {
+		for i, v := range artifacts {
+			fmt.Printf("%s/%s\n", v.RepositoryID, paths[i])
+		}
+		return nil
+	}
+
+	fmt.Fprintln(os.Stderr, "The following files will be deleted:")
+	for i, v := range artifacts {
+		fmt.Fprintf(os.Stderr, "  %v (%s/%s)\n", v, v.RepositoryID, paths[i])
+	}
+	if !s.Yes {
+		ok, err := confirm(fmt.Sprintf("Delete %d files?", len(artifacts)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("aborted")
+		}
+	}
+
+	for i, v := range artifacts {
+		if err := deleteFile(ctx, n, v.RepositoryID, paths[i], creds); err != nil {
+			return err
+		}
+		fmt.Println(v)
+	}
+	return nil
+}
+
+// deleteVersions removes the versions of artifacts as a whole. Unlike
+// deleting the resolved files this includes every build of a snapshot, not
+// just the latest one. The resolved files are listed under each version so
+// that they can be checked before anything is deleted.
+func (s *DeleteCommand) deleteVersions(ctx context.Context, n contextClient, artifacts []Artifact, creds credentials.Credentials) error {
+	type version struct {
+		RepositoryID, GroupID, ArtifactID, Version string
+	}
+	var versions []version
+	files := make(map[version][]string)
+	for _, v := range artifacts {
+		key := version{v.RepositoryID, v.GroupID, v.ArtifactID, v.Version}
+		if _, ok := files[key]; !ok {
+			versions = append(versions, key)
+		}
+		path, err := repositoryPathOf(ctx, n, v.Artifact, creds)
+		if err != nil {
+			return err
+		}
+		files[key] = append(files[key], v.RepositoryID+"/"+path)
+	}
+	path := func(v version) string {
+		return strings.Join([]string{v.RepositoryID, strings.Replace(v.GroupID, ".", "/", -1), v.ArtifactID, v.Version}, "/") + "/"
+	}
+
+	if s.DryRun {
+		for _, v := range versions {
+			fmt.Println(path(v))
+			for _, f := range files[v] {
+				fmt.Println(f)
+			}
+		}
+		return nil
+	}
+
+	fmt.Fprintln(os.Stderr, "The following versions will be deleted with all their files:")
+	for _, v := range versions {
+		fmt.Fprintf(os.Stderr, "  %s:%s:%s@%s (%s)\n", v.GroupID, v.ArtifactID, v.Version, v.RepositoryID, path(v))
+		for _, f := range files[v] {
+			fmt.Fprintf(os.Stderr, "    %s\n", f)
+		}
+	}
+	if !s.Yes {
+		ok, err := confirm(fmt.Sprintf("Delete %d versions?", len(versions)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("aborted")
+		}
+	}
+
+	for _, v := range versions {
+		if err := deleteVersion(ctx, n, v.RepositoryID, v.GroupID, v.ArtifactID, v.Version, creds); err != nil {
+			return err
+		}
+		fmt.Printf("%s:%s:%s@%s\n", v.GroupID, v.ArtifactID, v.Version, v.RepositoryID)
+	}
+	return nil
+}
+
+// confirm asks a yes/no question on the terminal.
+func confirm(question string) (bool, error) {
+	if !isTerminal(os.Stdin) {
+		return false, errors.New("cannot ask for confirmation, stdin is not a terminal; use --yes")
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// deleteFile removes a file and its checksum files from a repository.
+func deleteFile(ctx context.Context, n contextClient, repositoryID, path string, creds credentials.Credentials) error {
+	url, err := deleteURL(n, repositoryID, path)
+	if err != nil {
+		return err
+	}
+	lg.Infoln("deleting", url)
+	resp, err := doRequest(ctx, "DELETE", url, nil, 0, creds)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	for _, algo := range []string{"sha1", "md5"} {
+		resp, err := doRequest(ctx, "DELETE", url+"."+algo, nil, 0, creds)
+		if err != nil {
+			if e, ok := err.(nexus.Error); ok && e.StatusCode == http.StatusNotFound {
+				continue
+			}
+			return err
+		}
+		resp.Body.Close()
+	}
+	return nil
+}
+
+var deleteCommand DeleteCommand
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hanjos/nexus"
+	"github.com/hanjos/nexus/credentials"
+	"github.com/thomasf/lg"
+)
+
+type DeployCommand struct {
+	Repo  string `long:"repo" short:"r" description:"Repository to deploy to, may also be given as a @repository suffix"`
+	POM   string `long:"pom" description:"POM file to deploy instead of a generated one"`
+	NoPOM bool   `long:"no-pom" description:"Do not deploy a POM"`
+	Force bool   `long:"force" description:"Overwrite existing release artifacts"`
+}
+
+// deployFile is a single file to upload.
+type deployFile struct {
+	Artifact *nexus.Artifact
+	Path     string // local file, empty for generated content
+	Content  []byte // generated content
+}
+
+func (s *DeployCommand) Execute(args []string) error {
+	ctx := rootContext
+	if len(args) < 2 {
+		return usageErrorf("usage: deploy g:a:[e[:c]]:v file [classifier=file...]")
+	}
+	gav, err := parseArtifact(args[0])
+	if err != nil {
+		return err
+	}
+	if s.Repo != "" {
+		gav.RepositoryID = s.Repo
+	}
+	if gav.RepositoryID == "" {
+		return usageErrorf("no repository given, use --repo")
+	}
+
+	files, err := s.files(gav, args[1:])
+	if err != nil {
+		return err
+	}
+
+	creds, err := newCredentials()
+	if err != nil {
+		return err
+	}
+	n, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	urls := make([]string, len(files))
+	for i, f := range files {
+		urls[i], err = contentURL(n, f.Artifact.RepositoryID, mavenPath(f.Artifact))
+		if err != nil {
+			return err
+		}
+	}
+
+	// check everything before uploading anything
+	if !s.Force && !strings.HasSuffix(gav.Version, "SNAPSHOT") {
+		for i, f := range files {
+			found, err := exists(ctx, urls[i], creds)
+			if err != nil {
+				return err
+			}
+			if found {
+				return fmt.Errorf("%v already exists, use --force to overwrite it", f.Artifact)
+			}
+		}
+	}
+
+	out, err := newOutput(n, false)
+	if err != nil {
+		return err
+	}
+	for i, f := range files {
+		if err := upload(ctx, urls[i], f, creds); err != nil {
+			return err
+		}
+		a, err := newArtifact(f.Artifact)
+		if err != nil {
+			return err
+		}
+		if err := out.Write(a, nil, f.Path); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// files returns the files to deploy from the command line arguments. The
+// first file is the main artifact, the following ones are given as
+// classifier=path. The extension is taken from the file name unless it is
+// given in the coordinates.
+func (s *DeployCommand) files(gav *nexus.Artifact, args []string) ([]deployFile, error) {
+	var files []deployFile
+
+	primary := *gav
+	if primary.Extension == "" {
+		primary.Extension = fileExtension(args[0])
+		if primary.Extension == "" {
+			return nil, usageErrorf("%s has no file extension, give it in the coordinates as g:a:e:v", args[0])
+		}
+	}
+	files = append(files, deployFile{Artifact: &primary, Path: args[0]})
+
+	for _, arg := range args[1:] {
+		pos := strings.Index(arg, "=")
+		if pos < 1 {
+			return nil, usageErrorf("expected classifier=file, got %s", arg)
+		}
+		a := *gav
+		a.Classifier = arg[:pos]
+		a.Extension = fileExtension(arg[pos+1:])
+		if a.Extension == "" {
+			return nil, usageErrorf("%s has no file extension", arg[pos+1:])
+		}
+		files = append(files, deployFile{Artifact: &a, Path: arg[pos+1:]})
+	}
+
+	if !s.NoPOM && primary.Extension != "pom" {
+		pom := *gav
+		pom.Classifier = ""
+		pom.Extension = "pom"
+		if s.POM != "" {
+			files = append(files, deployFile{Artifact: &pom, Path: s.POM})
+		} else {
+			content, err := generatePOM(&primary)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, deployFile{Artifact: &pom, Content: content})
+		}
+	}
+
+	// fail before any request for files which cannot be uploaded
+	for _, f := range files {
+		if f.Path == "" {
+			continue
+		}
+		fi, err := os.Stat(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s is not a regular file", f.Path)
+		}
+	}
+	return files, nil
+}
+
+// fileExtension returns the extension of a file name without the dot,
+// keeping compressed tar extensions like tar.gz together.
+func fileExtension(path string) string {
+	base := filepath.Base(path)
+	for _, ext := range []string{".tar.gz", ".tar.bz2", ".tar.xz"} {
+		if strings.HasSuffix(base, ext) {
+			return ext[1:]
+		}
+	}
+	return strings.TrimPrefix(filepath.Ext(base), ".")
+}
+
+// generatePOM returns a minimal POM for the artifact.
+func generatePOM(a *nexus.Artifact) ([]byte, error) {
+	pom := struct {
+		XMLName      xml.Name `xml:"project"`
+		XMLNS        string   `xml:"xmlns,attr"`
+		ModelVersion string   `xml:"modelVersion"`
+		GroupID      string   `xml:"groupId"`
+		ArtifactID   string   `xml:"artifactId"`
+		Version      string   `xml:"version"`
+		Packaging    string   `xml:"packaging"`
+		Description  string   `xml:"description"`
+	}{
+		XMLNS:        "http://maven.apache.org/POM/4.0.0",
+		ModelVersion: "4.0.0",
+		GroupID:      a.GroupID,
+		ArtifactID:   a.ArtifactID,
+		Version:      a.Version,
+		Packaging:    a.Extension,
+		Description:  "POM was generated by nexus-cli",
+	}
+	b, err := xml.MarshalIndent(pom, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(b, '\n')...), nil
+}
+
+// upload puts f at url followed by its .sha1 and .md5 checksums.
+func upload(ctx context.Context, url string, f deployFile, creds credentials.Credentials) error {
+	var r io.ReadSeeker = bytes.NewReader(f.Content)
+	size := int64(len(f.Content))
+	if f.Path != "" {
+		file, err := os.Open(f.Path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		fi, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		r, size = file, fi.Size()
+	}
+
+	sha, md := sha1.New(), md5.New()
+	if _, err := io.Copy(io.MultiWriter(sha, md), r); err != nil {
+		return err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	lg.Infoln("uploading", url)
+	if err := put(ctx, url, r, size, creds); err != nil {
+		return err
+	}
+	for algo, h := range map[string]hash.Hash{"sha1": sha, "md5": md} {
+		sum := hex.EncodeToString(h.Sum(nil))
+		if err := put(ctx, url+"."+algo, strings.NewReader(sum), int64(len(sum)), creds); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func put(ctx context.Context, url string, body io.Reader, size int64, creds credentials.Credentials) error {
+	resp, err := doRequest(ctx, "PUT", url, body, size, creds)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+var deployCommand DeployCommand
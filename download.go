@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hanjos/nexus"
+	"github.com/hanjos/nexus/credentials"
+	"github.com/thomasf/lg"
+)
+
+// checksum is an expected digest of a download.
+type checksum struct {
+	Algorithm string // sha1, md5 or sha256
+	Value     string // hex encoded
+	Source    string // where the value came from, e.g. the sidecar URL
+}
+
+// hashes are the supported checksum algorithms, also used as the extension
+// of the sidecar files.
+var hashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"md5":    md5.New,
+	"sha256": sha256.New,
+}
+
+// ChecksumError is returned when a downloaded file does not match an expected
+// checksum.
+type ChecksumError struct {
+	URL      string
+	Expected checksum
+	Actual   string
+}
+
+func (err ChecksumError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch for %s: expected %s (from %s), got %s",
+		err.Expected.Algorithm, err.URL, err.Expected.Value, err.Expected.Source, err.Actual)
+}
+
+// fetchChecksums returns the checksums found in the .sha1, .md5 and .sha256
+// files next to url. Missing files are skipped.
+func fetchChecksums(ctx context.Context, url string, creds credentials.Credentials) ([]checksum, error) {
+	var result []checksum
+	for _, algo := range []string{"sha1", "md5", "sha256"} {
+		req, err := http.NewRequestWithContext(ctx, "GET", url+"."+algo, nil)
+		if err != nil {
+			return nil, err
+		}
+		creds.Sign(req)
+
+		resp, err := httpClient().Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			lg.V(5).Infof("no %s checksum for %s: %s", algo, url, resp.Status)
+			continue
+		}
+
+		// the file may also contain the file name after the digest
+		fields := strings.Fields(string(body))
+		if len(fields) == 0 {
+			continue
+		}
+		result = append(result, checksum{
+			Algorithm: algo,
+			Value:     strings.ToLower(fields[0]),
+			Source:    req.URL.String(),
+		})
+	}
+	return result, nil
+}
+
+// SizeError is returned when a downloaded file does not have the size
+// reported by Nexus.
+type SizeError struct {
+	URL      string
+	Expected int64
+	Actual   int64
+}
+
+func (err SizeError) Error() string {
+	return fmt.Sprintf("size mismatch for %s: expected %d bytes, got %d", err.URL, err.Expected, err.Actual)
+}
+
+// download fetches the artifact described by info into dst. The content is
+// written to dst+".part" which is renamed into place only after the response
+// status, the size and the given checksums are verified, so dst never holds a
+// partial file. The .part file and the directory of dst are only created for
+// a successful response. The transfer is reported to prog, which may be nil.
+//
+// A failed transfer leaves the .part file behind and the next download of dst
+// resumes it with a Range request. Servers which ignore the range get the
+// whole file downloaded again. The .part file is removed when the transfer is
+// cancelled by a signal.
+func download(ctx context.Context, dst string, info *nexus.ArtifactInfo, creds credentials.Credentials, checksums []checksum, prog *progress) error {
+	url := info.URL
+	part := dst + ".part"
+	expected := int64(info.Size)
+
+	var tmp *os.File
+	defer func() {
+		if tmp == nil {
+			return
+		}
+		tmp.Close()
+		if ctx.Err() != nil {
+			os.Remove(part)
+		}
+	}()
+
+	// hash what is already there, unless it can't be part of the artifact
+	digests := make(map[string]hash.Hash)
+	var writers []io.Writer
+	for _, c := range checksums {
+		if _, ok := digests[c.Algorithm]; !ok {
+			h := hashes[c.Algorithm]()
+			digests[c.Algorithm] = h
+			writers = append(writers, h)
+		}
+	}
+	var offset int64
+	tmp, err := os.OpenFile(part, os.O_RDWR, 0)
+	switch {
+	case err == nil:
+		offset, err = io.Copy(io.MultiWriter(append(writers, ioutil.Discard)...), tmp)
+		if err != nil {
+			return err
+		}
+	case os.IsNotExist(err):
+		// nothing to resume
+	default:
+		return err
+	}
+	if expected > 0 && offset >= expected {
+		offset = 0
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	creds.Sign(req)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && contentRangeStart(resp) == offset:
+		lg.Infof("resuming %s at %d bytes", dst, offset)
+	case resp.StatusCode == http.StatusOK:
+		offset = 0
+	case resp.StatusCode == http.StatusPartialContent:
+		// start over on the next attempt
+		tmp.Close()
+		os.Remove(part)
+		return fmt.Errorf("unexpected Content-Range %q from %s, requested offset %d",
+			resp.Header.Get("Content-Range"), url, offset)
+	case resp.StatusCode == http.StatusUnauthorized:
+		return &credentials.Error{URL: url, Credentials: creds}
+	default:
+		return nexus.Error{
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Message:    fmt.Sprintf("Error (%v) from %v", resp.Status, url),
+		}
+	}
+
+	if offset == 0 {
+		for _, h := range digests {
+			h.Reset()
+		}
+	}
+	if tmp == nil {
+		if err := os.MkdirAll(filepath.Dir(dst), 0775); err != nil {
+			return err
+		}
+		if tmp, err = os.OpenFile(part, os.O_RDWR|os.O_CREATE, 0644); err != nil {
+			return err
+		}
+	}
+	if err := tmp.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	size := expected
+	if size <= 0 && resp.ContentLength > 0 {
+		size = offset + resp.ContentLength
+	}
+	prog.begin(size, offset)
+	defer prog.end()
+
+	n, err := io.Copy(io.MultiWriter(append(writers, tmp, prog)...), resp.Body)
+	if err != nil {
+		// keep the .part file for the next attempt
+		return err
+	}
+	n += offset
+
+	if expected > 0 && n != expected {
+		tmp.Close()
+		os.Remove(part)
+		return SizeError{URL: url, Expected: expected, Actual: n}
+	}
+	for _, c := range checksums {
+		actual := hex.EncodeToString(digests[c.Algorithm].Sum(nil))
+		if !strings.EqualFold(actual, c.Value) {
+			tmp.Close()
+			os.Remove(part)
+			return ChecksumError{URL: url, Expected: c, Actual: actual}
+		}
+		lg.V(5).Infof("%s %s ok", c.Algorithm, dst)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(part, dst)
+}
+
+// contentRangeStart returns the first byte position of a 206 response, or -1
+// if it can't be parsed.
+func contentRangeStart(resp *http.Response) int64 {
+	var start, end, size int64
+	_, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &size)
+	if err != nil {
+		// the size may be unknown
+		_, err = fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-%d/*", &start, &end)
+		if err != nil {
+			return -1
+		}
+	}
+	return start
+}
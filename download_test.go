@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hanjos/nexus"
+	"github.com/hanjos/nexus/credentials"
+	"github.com/hanjos/nexus/util"
+)
+
+func TestDownload(t *testing.T) {
+	content := strings.Repeat("0123456789", 1000)
+	sum := sha1.Sum([]byte(content))
+	sha := hex.EncodeToString(sum[:])
+
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		switch r.URL.Path {
+		case "/lib.jar":
+			http.ServeContent(w, r, "lib.jar", time.Time{}, strings.NewReader(content))
+		case "/norange.jar":
+			w.Write([]byte(content))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		path      string
+		part      string // existing .part content
+		size      int    // expected size, defaults to the content's
+		wantRange string
+		wantExit  int
+	}{
+		{name: "fresh", path: "/lib.jar"},
+		{name: "resume", path: "/lib.jar", part: content[:4000], wantRange: "bytes=4000-"},
+		{name: "range ignored", path: "/norange.jar", part: content[:4000], wantRange: "bytes=4000-"},
+		{name: "complete part", path: "/lib.jar", part: content},
+		{name: "corrupt part", path: "/lib.jar", part: strings.Repeat("x", 4000), wantRange: "bytes=4000-", wantExit: exitChecksum},
+		{name: "size mismatch", path: "/lib.jar", size: len(content) + 1, wantExit: exitChecksum},
+		{name: "not found", path: "/missing.jar", wantExit: exitNotFound},
+	}
+	for _, tt := range tests {
+		// the directory is only created for a successful response
+		dst := filepath.Join(t.TempDir(), "g", "a", "1.0", "lib.jar")
+		if tt.part != "" {
+			if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(dst+".part", []byte(tt.part), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		size := tt.size
+		if size == 0 {
+			size = len(content)
+		}
+		info := &nexus.ArtifactInfo{URL: srv.URL + tt.path, Size: util.ByteSize(size), Sha1: sha}
+		checksums := []checksum{{Algorithm: "sha1", Value: sha, Source: "test"}}
+		ranges = nil
+
+		err := download(context.Background(), dst, info, credentials.None, checksums, nil)
+		if len(ranges) != 1 || ranges[0] != tt.wantRange {
+			t.Errorf("%s: requests with ranges %q, want one with %q", tt.name, ranges, tt.wantRange)
+		}
+		if tt.wantExit == 0 {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+				continue
+			}
+			if b, err := ioutil.ReadFile(dst); err != nil || string(b) != content {
+				t.Errorf("%s: got %d bytes, %v", tt.name, len(b), err)
+			}
+		} else if code, _ := exitCode(err); code != tt.wantExit {
+			t.Errorf("%s: got error %v with exit code %d, want %d", tt.name, err, code, tt.wantExit)
+		}
+		if _, err := os.Stat(dst + ".part"); !os.IsNotExist(err) {
+			t.Errorf("%s: the .part file is left behind", tt.name)
+		}
+		if tt.wantExit == exitNotFound {
+			if _, err := os.Stat(filepath.Dir(dst)); !os.IsNotExist(err) {
+				t.Errorf("%s: the directory was created", tt.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/hanjos/nexus"
+	"github.com/hanjos/nexus/credentials"
+	"github.com/hanjos/nexus/util"
+	"github.com/jessevdk/go-flags"
+)
+
+// The exit codes, they are documented in the README so keep them stable.
+const (
+	exitError        = 1   // any other error
+	exitUsage        = 2   // invalid command line, config or URL
+	exitNotFound     = 3   // no matching artifact or 404 Not Found
+	exitUnauthorized = 4   // missing or invalid credentials
+	exitForbidden    = 5   // the user lacks a privilege
+	exitServer       = 6   // 5xx server error
+	exitNetwork      = 7   // the server could not be reached
+	exitChecksum     = 8   // a download did not match its checksum or size
+	exitInterrupted  = 130 // cancelled by SIGINT or SIGTERM
+)
+
+// errNoMatch is returned when no artifact matches the given coordinates.
+var errNoMatch = errors.New("no matching artifact")
+
+// usageError is an invalid command line.
+type usageError string
+
+func usageErrorf(format string, a ...interface{}) error {
+	return usageError(fmt.Sprintf(format, a...))
+}
+
+func (err usageError) Error() string {
+	return string(err)
+}
+
+// downloadsFailed is returned when some of several downloads failed.
+type downloadsFailed struct {
+	Errors []error
+	Total  int
+}
+
+func (err downloadsFailed) Error() string {
+	return fmt.Sprintf("%d of %d downloads failed", len(err.Errors), err.Total)
+}
+
+// exitCode returns the exit code for err and a name for its kind.
+func exitCode(err error) (int, string) {
+	switch e := err.(type) {
+	case usageError, *util.MalformedURLError:
+		return exitUsage, "usage"
+	case *flags.Error:
+		return exitUsage, "usage"
+	case *flags.IniError:
+		return exitUsage, "config"
+	case *credentials.Error:
+		return exitUnauthorized, "unauthorized"
+	case nexus.Error:
+		switch {
+		case e.StatusCode == http.StatusUnauthorized:
+			return exitUnauthorized, "unauthorized"
+		case e.StatusCode == http.StatusForbidden:
+			return exitForbidden, "forbidden"
+		case e.StatusCode == http.StatusNotFound:
+			return exitNotFound, "not_found"
+		case e.StatusCode >= 500:
+			return exitServer, "server"
+		}
+		return exitError, "error"
+	case ChecksumError, SizeError:
+		return exitChecksum, "checksum"
+	case downloadsFailed:
+		// the common exit code if all downloads failed the same way
+		code, kind := exitCode(e.Errors[0])
+		for _, err := range e.Errors[1:] {
+			if c, _ := exitCode(err); c != code {
+				return exitError, "error"
+			}
+		}
+		return code, kind
+	}
+	if err == errNoMatch {
+		return exitNotFound, "not_found"
+	}
+	if errors.Is(err, context.Canceled) {
+		return exitInterrupted, "interrupted"
+	}
+	// local files, checked before the network errors as syscall.Errno is a
+	// net.Error too
+	var pathErr *os.PathError
+	var linkErr *os.LinkError
+	if errors.As(err, &pathErr) || errors.As(err, &linkErr) {
+		return exitError, "error"
+	}
+	var urlErr *url.Error
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	var stalled stalledError
+	if errors.As(err, &urlErr) || errors.As(err, &opErr) || errors.As(err, &dnsErr) ||
+		errors.As(err, &stalled) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return exitNetwork, "network"
+	}
+	return exitError, "error"
+}
+
+// exit reports err on stderr, as JSON with --format json or jsonl, and exits
+// with its exit code.
+func exit(err error) {
+	code, kind := exitCode(err)
+	if options.Format != formatJSON && options.Format != formatJSONL {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(code)
+	}
+
+	type jsonError struct {
+		Kind       string `json:"kind"`
+		Message    string `json:"message"`
+		ExitCode   int    `json:"exitCode"`
+		URL        string `json:"url,omitempty"`
+		StatusCode int    `json:"statusCode,omitempty"`
+	}
+	e := jsonError{Kind: kind, Message: err.Error(), ExitCode: code}
+	switch err := err.(type) {
+	case *credentials.Error:
+		e.URL, e.StatusCode = err.URL, http.StatusUnauthorized
+	case nexus.Error:
+		e.URL, e.StatusCode = err.URL, err.StatusCode
+	case ChecksumError:
+		e.URL = err.URL
+	case SizeError:
+		e.URL = err.URL
+	}
+	enc := json.NewEncoder(os.Stderr)
+	enc.SetEscapeHTML(false)
+	enc.Encode(struct {
+		Error jsonError `json:"error"`
+	}{e})
+	os.Exit(code)
+}
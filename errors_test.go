@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hanjos/nexus"
+	"github.com/hanjos/nexus/credentials"
+	"github.com/jessevdk/go-flags"
+)
+
+func TestExitCode(t *testing.T) {
+	dial := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"usage", usageErrorf("missing artifact coordinates"), exitUsage},
+		{"flags", &flags.Error{Type: flags.ErrUnknownFlag}, exitUsage},
+		{"no match", errNoMatch, exitNotFound},
+		{"credentials", &credentials.Error{URL: "http://nexus", Credentials: credentials.None}, exitUnauthorized},
+		{"401", nexus.Error{StatusCode: 401}, exitUnauthorized},
+		{"403", nexus.Error{StatusCode: 403}, exitForbidden},
+		{"404", nexus.Error{StatusCode: 404}, exitNotFound},
+		{"400", nexus.Error{StatusCode: 400}, exitError},
+		{"500", nexus.Error{StatusCode: 500}, exitServer},
+		{"503", nexus.Error{StatusCode: 503}, exitServer},
+		{"checksum", ChecksumError{}, exitChecksum},
+		{"size", SizeError{}, exitChecksum},
+		{"missing file", &os.PathError{Op: "open", Path: "/nope.jar", Err: syscall.ENOENT}, exitError},
+		{"read-only directory", &os.PathError{Op: "open", Path: "/x.jar.part", Err: syscall.EACCES}, exitError},
+		{"rename", &os.LinkError{Op: "rename", Old: "x.part", New: "x", Err: syscall.ENOENT}, exitError},
+		{"errno", syscall.ENOSPC, exitError},
+		{"connection refused", &url.Error{Op: "Get", URL: "http://nexus", Err: dial}, exitNetwork},
+		{"dial", dial, exitNetwork},
+		{"dns", &net.DNSError{Err: "no such host", Name: "nexus"}, exitNetwork},
+		{"cut short", io.ErrUnexpectedEOF, exitNetwork},
+		{"stalled", stalledError{URL: "http://nexus/x.jar", Idle: time.Minute}, exitNetwork},
+		{"cancelled", context.Canceled, exitInterrupted},
+		{"cancelled request", &url.Error{Op: "Get", URL: "http://nexus", Err: context.Canceled}, exitInterrupted},
+		{"other", errors.New("something"), exitError},
+		{"downloads failed alike", downloadsFailed{
+			Errors: []error{nexus.Error{StatusCode: 404}, errNoMatch},
+			Total:  3,
+		}, exitNotFound},
+		{"downloads failed differently", downloadsFailed{
+			Errors: []error{nexus.Error{StatusCode: 404}, io.ErrUnexpectedEOF},
+			Total:  2,
+		}, exitError},
+	}
+	for _, tt := range tests {
+		if got, _ := exitCode(tt.err); got != tt.want {
+			t.Errorf("%s: exitCode(%#v) = %d, want %d", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/thomasf/lg"
+)
+
+// rootContext is cancelled on SIGINT and SIGTERM. The commands send every
+// request with it, which aborts all transfers in flight.
+var rootContext = context.Background()
+
+// cancelOnSignals cancels rootContext on SIGINT and SIGTERM. A second signal
+// terminates right away.
+func cancelOnSignals() {
+	var stop context.CancelFunc
+	rootContext, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-rootContext.Done()
+		stop()
+		lg.Warningln("interrupted, cancelling")
+	}()
+}
+
+// idleTransport fails responses whose body delivers no data for Timeout, so
+// that a stalled transfer ends with an error instead of hanging. Unlike a
+// limit on the whole request this does not cut off large transfers.
+type idleTransport struct {
+	http.RoundTripper
+	Timeout time.Duration
+}
+
+func (t idleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Timeout <= 0 {
+		return t.RoundTripper.RoundTrip(req)
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	resp, err := t.RoundTripper.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	b := &idleBody{ReadCloser: resp.Body, url: req.URL.String(), timeout: t.Timeout, cancel: cancel}
+	b.timer = time.AfterFunc(t.Timeout, b.stall)
+	b.timer.Stop()
+	resp.Body = b
+	return resp, nil
+}
+
+// idleBody cancels the request when a Read waits longer than timeout.
+type idleBody struct {
+	io.ReadCloser
+	url     string
+	timeout time.Duration
+	cancel  context.CancelFunc
+	timer   *time.Timer
+	stalled int32
+}
+
+func (b *idleBody) stall() {
+	atomic.StoreInt32(&b.stalled, 1)
+	b.cancel()
+}
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	b.timer.Reset(b.timeout)
+	n, err := b.ReadCloser.Read(p)
+	b.timer.Stop()
+	if err != nil && atomic.LoadInt32(&b.stalled) != 0 {
+		err = stalledError{URL: b.url, Idle: b.timeout}
+	}
+	return n, err
+}
+
+func (b *idleBody) Close() error {
+	b.timer.Stop()
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// stalledError is returned when a response body delivers no data for the
+// --timeout.
+type stalledError struct {
+	URL  string
+	Idle time.Duration
+}
+
+func (err stalledError) Error() string {
+	return fmt.Sprintf("no data received from %s for %v", err.URL, err.Idle)
+}
+
+// Timeout reports the error as a timeout, like the net package does.
+func (err stalledError) Timeout() bool {
+	return true
+}
+
+// retryTransport retries GET and HEAD requests on connection errors and
+// responses which indicate a temporarily unavailable server, waiting with
+// exponential backoff and jitter or as long as the server asks for with
+// Retry-After.
+type retryTransport struct {
+	http.RoundTripper
+	Retries int
+	MaxWait time.Duration
+}
+
+// retryStatus are the response codes worth another try.
+var retryStatus = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+func (t retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return t.RoundTripper.RoundTrip(req)
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := t.RoundTripper.RoundTrip(req)
+		if attempt >= t.Retries || req.Context().Err() != nil {
+			return resp, err
+		}
+		if err != nil && !retryable(err) {
+			return nil, err
+		}
+		if err == nil && !retryStatus[resp.StatusCode] {
+			return resp, nil
+		}
+
+		wait := t.backoff(attempt)
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			if d, ok := retryAfter(resp); ok {
+				wait = d
+				if wait > t.MaxWait {
+					wait = t.MaxWait
+				}
+			}
+			io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+		lg.V(2).Infof("%s %s: %s, retry %d of %d in %v", req.Method, req.URL, reason, attempt+1, t.Retries, wait)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// retryable returns true for errors which another try may not run into: failed
+// or dropped connections and timeouts. Unknown hosts, certificate errors and
+// the like would only fail again.
+func retryable(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && (opErr.Op == "dial" || opErr.Op == "read" || opErr.Op == "write") {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// backoff returns the wait before retry attempt+1: half a second doubled for
+// each attempt, up to MaxWait, of which a random part of up to a half is
+// taken off.
+func (t retryTransport) backoff(attempt int) time.Duration {
+	d := 500 * time.Millisecond << uint(attempt)
+	if d > t.MaxWait || d <= 0 {
+		d = t.MaxWait
+	}
+	return d - time.Duration(rand.Int63n(int64(d)/2+1))
+}
+
+// retryAfter returns the wait a response asks for in its Retry-After header,
+// given in seconds or as a date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+var (
+	httpClientOnce sync.Once
+	sharedClient   *http.Client
+)
+
+// httpClient returns the client for all requests, which uses the configured
+// timeouts and retries. There is no limit on the total time of a request so
+// that large transfers are not cut off, --timeout limits the wait for the
+// response of the server and for each read of its body.
+func httpClient() *http.Client {
+	httpClientOnce.Do(func() {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.DialContext = (&net.Dialer{
+			Timeout:   options.ConnectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+		t.TLSHandshakeTimeout = options.ConnectTimeout
+		t.ResponseHeaderTimeout = options.Timeout
+		sharedClient = &http.Client{
+			Transport: retryTransport{
+				RoundTripper: idleTransport{RoundTripper: t, Timeout: options.Timeout},
+				Retries:      options.Retries,
+				MaxWait:      options.RetryMaxWait,
+			},
+		}
+	})
+	return sharedClient
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIdleTransport(t *testing.T) {
+	// sends a chunk every 20ms, stalling after the first one for /stall
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 5; i++ {
+			w.Write([]byte("chunk"))
+			w.(http.Flusher).Flush()
+			if r.URL.Path == "/stall" {
+				<-r.Context().Done()
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: idleTransport{RoundTripper: http.DefaultTransport, Timeout: 200 * time.Millisecond}}
+
+	resp, err := client.Get(srv.URL + "/steady")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || len(b) != 25 {
+		t.Errorf("steady: got %d bytes, %v; want 25 bytes", len(b), err)
+	}
+
+	resp, err = client.Get(srv.URL + "/stall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	var stalled stalledError
+	if !errors.As(err, &stalled) || len(b) != 5 {
+		t.Errorf("stall: got %d bytes, %v; want 5 bytes and a stalledError", len(b), err)
+	}
+}
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		statuses   []int // the responses in turn, the last one repeats
+		retryAfter string
+		wantStatus int
+		wantHits   int32
+	}{
+		{"503 then 200", "GET", []int{503, 200}, "", 200, 2},
+		{"capped Retry-After", "GET", []int{503, 200}, "3600", 200, 2},
+		{"no retry for PUT", "PUT", []int{503, 200}, "", 503, 1},
+		{"not found", "GET", []int{404, 200}, "", 404, 1},
+		{"gives up", "HEAD", []int{502}, "", 502, 3},
+	}
+	for _, tt := range tests {
+		var hits int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			i := int(atomic.AddInt32(&hits, 1)) - 1
+			if i >= len(tt.statuses) {
+				i = len(tt.statuses) - 1
+			}
+			if tt.retryAfter != "" {
+				w.Header().Set("Retry-After", tt.retryAfter)
+			}
+			w.WriteHeader(tt.statuses[i])
+		}))
+		client := &http.Client{Transport: retryTransport{RoundTripper: http.DefaultTransport, Retries: 2, MaxWait: 50 * time.Millisecond}}
+
+		req, _ := http.NewRequest(tt.method, srv.URL, nil)
+		start := time.Now()
+		resp, err := client.Do(req)
+		srv.Close()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.wantStatus || hits != tt.wantHits {
+			t.Errorf("%s: got %d after %d requests, want %d after %d", tt.name, resp.StatusCode, hits, tt.wantStatus, tt.wantHits)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("%s: took %v, the waits are at most 50ms", tt.name, d)
+		}
+	}
+}
+
+// failingTransport fails every request with err.
+type failingTransport struct {
+	err   error
+	calls int
+}
+
+func (t *failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls++
+	return nil, t.err
+}
+
+func TestRetryTransportErrors(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	tests := []struct {
+		name      string
+		err       error
+		wantCalls int
+	}{
+		{"connection refused", refused, 3},
+		{"connection reset", &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, 3},
+		{"cut short", io.ErrUnexpectedEOF, 3},
+		{"no such host", &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "nexus", IsNotFound: true}}, 1},
+		{"dns timeout", &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "timeout", Name: "nexus", IsTimeout: true}}, 3},
+		{"other", errors.New("x509: certificate signed by unknown authority"), 1},
+	}
+	for _, tt := range tests {
+		ft := &failingTransport{err: tt.err}
+		rt := retryTransport{RoundTripper: ft, Retries: 2, MaxWait: time.Millisecond}
+		req, _ := http.NewRequest("GET", "http://nexus/", nil)
+		if _, err := rt.RoundTrip(req); err != tt.err {
+			t.Errorf("%s: got error %v, want %v", tt.name, err, tt.err)
+		}
+		if ft.calls != tt.wantCalls {
+			t.Errorf("%s: %d calls, want %d", tt.name, ft.calls, tt.wantCalls)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	rt := retryTransport{MaxWait: 3 * time.Second}
+	for attempt, want := range []time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		for i := 0; i < 20; i++ {
+			if d := rt.backoff(attempt); d < want/2 || d > want {
+				t.Errorf("backoff(%d) = %v, want between %v and %v", attempt, d, want/2, want)
+			}
+		}
+	}
+	// no overflow for many attempts
+	if d := rt.backoff(100); d <= 0 || d > rt.MaxWait {
+		t.Errorf("backoff(100) = %v", d)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		if tt.header != "" {
+			resp.Header.Set("Retry-After", tt.header)
+		}
+		got, ok := retryAfter(resp)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("retryAfter(%q) = %v, %v, want %v, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	// a date in the future, which loses the sub-second part
+	resp := &http.Response{Header: http.Header{"Retry-After": {time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)}}}
+	if got, ok := retryAfter(resp); !ok || got < 58*time.Second || got > time.Minute {
+		t.Errorf("retryAfter(date in a minute) = %v, %v", got, ok)
+	}
+}
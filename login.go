@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hanjos/nexus/credentials"
+)
+
+type LoginCommand struct {
+	PasswordStdin bool `long:"password-stdin" description:"Read the password from stdin"`
+}
+
+func (s *LoginCommand) Execute(args []string) error {
+	ctx := rootContext
+	if options.Host == "" {
+		return usageErrorf("no host given, use --host")
+	}
+	var err error
+	username, password := options.User, options.Password
+	if username == "" {
+		if username, err = ask("Username: ", false); err != nil {
+			return err
+		}
+	}
+	switch {
+	case s.PasswordStdin:
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("reading the password from stdin: %v", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	case password == "":
+		if password, err = ask("Password: ", true); err != nil {
+			return err
+		}
+	}
+
+	store, err := newStore()
+	if err != nil {
+		return err
+	}
+	creds, err := withHeader(credentials.BasicAuth(username, password))
+	if err != nil {
+		return err
+	}
+	n, err := clientFor(ctx, creds)
+	if err != nil {
+		return err
+	}
+	a, err := accountOf(ctx, n, creds)
+	if err != nil {
+		return err
+	}
+
+	if err := store.store(options.Host, username, password); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Logged in as %s, credentials stored in %v\n", username, store)
+	return a.write(os.Stdout)
+}
+
+type LogoutCommand struct{}
+
+func (s *LogoutCommand) Execute(args []string) error {
+	if options.Host == "" {
+		return usageErrorf("no host given, use --host")
+	}
+	store, err := newStore()
+	if err != nil {
+		return err
+	}
+	if err := store.erase(options.Host); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Removed the credentials for %s from %v\n", options.Host, store)
+	return nil
+}
+
+// ask reads an answer from the terminal, without echoing it if secret is
+// set.
+func ask(question string, secret bool) (string, error) {
+	if !isTerminal(os.Stdin) {
+		return "", fmt.Errorf("cannot ask for %s, stdin is not a terminal", strings.ToLower(strings.TrimSuffix(question, ": ")))
+	}
+	fmt.Fprint(os.Stderr, question)
+	if secret {
+		return readPassword(os.Stdin)
+	}
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+var loginCommand LoginCommand
+var logoutCommand LogoutCommand
@@ -1,31 +1,50 @@
 package main
 
 import (
-	"bytes"
-	"errors"
+	"context"
+	"encoding/xml"
 	"flag"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"path/filepath"
-	"reflect"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/hanjos/nexus"
 	"github.com/hanjos/nexus/credentials"
 	"github.com/hanjos/nexus/search"
+	"github.com/hanjos/nexus/util"
 	version "github.com/hashicorp/go-version"
 	flags "github.com/jessevdk/go-flags"
 	"github.com/thomasf/lg"
 )
 
+// Options are the global options. They are read from the config file, the
+// selected profile, the environment and the command line, each overriding
+// the ones before.
 type Options struct {
-	User         string `long:"user" description:"username" ini-name:"username"`
-	Password     string `long:"password" description:"password" ini-name:"password"`
-	Host         string `long:"host" description:"nexus url" ini-name:"host"`
+	User             string        `long:"user" description:"username" ini-name:"username" env:"NEXUS_CLI_USER"`
+	Password         string        `long:"password" description:"password" ini-name:"password" env:"NEXUS_CLI_PASSWORD" default-mask:"***"`
+	Host             string        `long:"host" description:"nexus url" ini-name:"host" env:"NEXUS_CLI_HOST"`
+	Format           string        `long:"format" description:"output format" choice:"text" choice:"json" choice:"jsonl" choice:"csv" choice:"tsv" default:"text" ini-name:"format" env:"NEXUS_CLI_FORMAT"`
+	Template         string        `long:"template" description:"Go text/template for each result, overrides --format" ini-name:"template" env:"NEXUS_CLI_TEMPLATE"`
+	Quiet            bool          `long:"quiet" short:"q" description:"Do not report download progress" ini-name:"quiet" env:"NEXUS_CLI_QUIET"`
+	API              string        `long:"api" description:"Nexus REST API version, auto detects from the server by default" choice:"2" choice:"3" choice:"auto" default:"auto" ini-name:"api" env:"NEXUS_CLI_API"`
+	Token            string        `long:"token" description:"bearer token to authenticate with instead of --user and --password" ini-name:"token" env:"NEXUS_CLI_TOKEN" default-mask:"***"`
+	Header           string        `long:"header" description:"header to send with every request, e.g. 'X-Auth-Token: secret'" ini-name:"header" env:"NEXUS_CLI_HEADER" default-mask:"***"`
+	CredentialHelper string        `long:"credential-helper" description:"program to get the password from when none is given, or secret-service or file for the built-in stores" ini-name:"credential-helper" env:"NEXUS_CLI_CREDENTIAL_HELPER"`
+	MavenServer      string        `long:"maven-server" description:"use the credentials of this server id in ~/.m2/settings.xml instead of --user and --password" ini-name:"maven-server" env:"NEXUS_CLI_MAVEN_SERVER"`
+	Netrc            bool          `long:"netrc" description:"use the credentials for the host in ~/.netrc or $NETRC instead of --user and --password" ini-name:"netrc" env:"NEXUS_CLI_NETRC"`
+	Timeout          time.Duration `long:"timeout" description:"how long to wait for the server to respond or send more data, 0 for no limit" default:"2m" ini-name:"timeout" env:"NEXUS_CLI_TIMEOUT"`
+	ConnectTimeout   time.Duration `long:"connect-timeout" description:"how long to wait for a connection to the server" default:"30s" ini-name:"connect-timeout" env:"NEXUS_CLI_CONNECT_TIMEOUT"`
+	Retries          int           `long:"retries" description:"how often to retry requests failing with connection errors or 429, 502, 503 and 504 responses" default:"3" ini-name:"retries" env:"NEXUS_CLI_RETRIES"`
+	RetryMaxWait     time.Duration `long:"retry-max-wait" description:"the longest wait before a retry" default:"30s" ini-name:"retry-max-wait" env:"NEXUS_CLI_RETRY_MAX_WAIT"`
+	Profile          string        `long:"profile" description:"server profile, a [profile.NAME] section of the config file" ini-name:"default-profile" env:"NEXUS_CLI_PROFILE"`
+	Config           string        `long:"config" description:"config file, defaults to the first of ./settings.ini, $XDG_CONFIG_HOME/nexus-cli/config.ini and ~/.nexus-cli.ini" no-ini:"true" env:"NEXUS_CLI_CONFIG"`
 }
 
 type FilterOptions struct {
@@ -76,21 +95,42 @@ var parser *flags.Parser
 
 func main() {
 	flag.Set("logtostderr", "true")
-	flag.Set("logcolor", "true")
+	// the log colors are written to stdout as escape codes, keep them out of
+	// piped output
+	if isTerminal(os.Stdout) {
+		flag.Set("logcolor", "true")
+	}
 	flag.Set("v", "100")
 	flag.CommandLine.Parse([]string{})
 
 	lg.SetSrcHighlight("thomasf/nexus-cli")
-	parser = flags.NewParser(&options, flags.Default)
-	err := flags.IniParse("settings.ini", &options)
-	if err != nil {
-		log.Fatal(err)
-	}
-
+	cancelOnSignals()
+	// errors are reported by exit
+	parser = flags.NewParser(&options, flags.HelpFlag|flags.PassDoubleDash)
 	parser.AddCommand("search", "search repo", "", &searchCommand)
 	parser.AddCommand("get", "get artifact(s)", "", &getCommand)
+	parser.AddCommand("info", "show artifact metadata", "", &infoCommand)
+	parser.AddCommand("deploy", "deploy artifact(s) to a hosted repository", "", &deployCommand)
+	parser.AddCommand("delete", "delete artifact(s)", "", &deleteCommand)
+	parser.AddCommand("cleanup", "delete old versions", "", &cleanupCommand)
+	parser.AddCommand("promote", "copy artifact(s) to another repository", "", &promoteCommand)
+	parser.AddCommand("repos", "list repositories", "", &reposCommand)
+	parser.AddCommand("login", "check and store credentials, showing the accessible repositories", "", &loginCommand)
+	parser.AddCommand("logout", "remove stored credentials", "", &logoutCommand)
+	parser.AddCommand("whoami", "show the user, server version and accessible repositories", "", &whoamiCommand)
+	parser.AddCommand("profiles", "list the server profiles of the config file", "", &profilesCommand)
+	if err := loadConfig(parser, os.Args[1:]); err != nil {
+		if _, ok := err.(*flags.IniError); !ok {
+			err = usageError(err.Error())
+		}
+		exit(err)
+	}
 	if _, err := parser.Parse(); err != nil {
-		os.Exit(1)
+		if e, ok := err.(*flags.Error); ok && e.Type == flags.ErrHelp {
+			fmt.Println(e.Message)
+			return
+		}
+		exit(err)
 	}
 }
 
@@ -154,106 +194,501 @@ type SearchCommand struct {
 }
 
 func (s *SearchCommand) Execute(args []string) error {
-	if len(args) < 0 {
-		searchrepo("")
-		return nil
+	ctx := rootContext
+	n, err := newClient(ctx)
+	if err != nil {
+		return err
 	}
-	artifacts, err := searchrepo(args[0])
+	var q string
+	if len(args) > 0 {
+		q = args[0]
+	}
+	artifacts, err := searchrepo(ctx, n, q)
 	if err != nil {
 		return err
 	}
 
 	artifacts = s.FilterOptions.Filter(artifacts)
 
+	out, err := newOutput(n, false)
+	if err != nil {
+		return err
+	}
 	for _, v := range artifacts {
-		fmt.Println(v)
+		if err := out.Write(v, nil, ""); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return out.Flush()
 }
 
 var searchCommand SearchCommand
 
 type GetCommand struct {
 	Output        string `long:"out" short:"o" description:"output path"`
+	NoVerify      bool   `long:"no-verify" description:"Do not verify the downloaded files against their checksums"`
+	Sidecars      bool   `long:"sidecars" description:"Also verify against the .sha1, .md5 and .sha256 files next to the artifact"`
+	Jobs          int    `long:"jobs" short:"j" description:"Number of concurrent downloads" default:"4"`
 	FilterOptions FilterOptions
 }
 
-func (s *GetCommand) Execute(args []string) error {
+// getResult is the outcome of downloading a single artifact.
+type getResult struct {
+	Artifact Artifact
+	Info     *nexus.ArtifactInfo
+	Path     string
+	Err      error
+}
 
+func (s *GetCommand) Execute(args []string) error {
+	ctx := rootContext
+	if len(args) < 1 {
+		return usageErrorf("missing artifact coordinates")
+	}
 	gav := args[0]
 
-	creds := credentials.BasicAuth(options.User, options.Password)
-	n := nexus.New(options.Host, creds)
+	creds, err := newCredentials()
+	if err != nil {
+		return err
+	}
+	n, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
 
-	arts, err := n.Artifacts(ParseGAV(gav))
+	artifacts, err := resolve(ctx, n, gav, &s.FilterOptions)
 	if err != nil {
-		lg.Fatal(err)
+		return err
 	}
-	var artifacts []Artifact
-	for _, a := range arts {
-		art, err := newArtifact(a)
+	artifacts = distinctFiles(artifacts)
+	if s.Output != "" && len(artifacts) != 1 {
+		return usageErrorf("cannot use --out with multiple matches")
+	}
+
+	// the text format only logs what is downloaded
+	var out *artifactWriter
+	if options.Format != formatText || options.Template != "" {
+		out, err = newOutput(n, true)
 		if err != nil {
 			return err
 		}
-		artifacts = append(artifacts, art)
 	}
-	artifacts = s.FilterOptions.Filter(artifacts)
 
-	lg.Infoln(artifacts)
-	if len(artifacts) < 1 {
-		return errors.New("no matching artifact")
+	jobs := s.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	var prog *progress
+	if !options.Quiet {
+		prog = newProgress(os.Stderr)
+	}
+	prog.Start()
+
+	results := make([]getResult, len(artifacts))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = s.get(ctx, n, creds, artifacts[i], prog)
+			}
+		}()
+	}
+	for i := range artifacts {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	prog.Stop()
+
+	var failed []error
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r.Err)
+			continue
+		}
+		if out != nil {
+			if err := out.Write(r.Artifact, r.Info, r.Path); err != nil {
+				return err
+			}
+		}
+	}
+	if out != nil {
+		if err := out.Flush(); err != nil {
+			return err
+		}
 	}
 
-	if s.Output != "" {
-		if len(artifacts) != 1 {
-			return errors.New("cannot use --out with multiple matches")
+	if len(results) > 1 || len(failed) > 0 {
+		for _, r := range results {
+			if r.Err != nil {
+				fmt.Fprintf(os.Stderr, "FAILED %v: %v\n", r.Artifact, r.Err)
+			} else {
+				fmt.Fprintf(os.Stderr, "ok     %v: %s\n", r.Artifact, r.Path)
+			}
 		}
-		v := artifacts[0]
-		info, err := n.InfoOf(v.Artifact)
+	}
+	if len(failed) == 1 && len(results) == 1 {
+		return results[0].Err
+	}
+	if len(failed) > 0 {
+		return downloadsFailed{Errors: failed, Total: len(results)}
+	}
+	return nil
+}
+
+// get resolves and downloads a single artifact.
+func (s *GetCommand) get(ctx context.Context, n contextClient, creds credentials.Credentials, v Artifact, prog *progress) getResult {
+	r := getResult{Artifact: v}
+
+	info, err := infoOf(ctx, n, v)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	r.Info = info
+	lg.Infoln(info.URL)
+
+	// download creates the directory
+	dst := s.Output
+	if dst == "" {
+		dst = filepath.Join(v.GroupID, v.ArtifactID, v.Version, filepath.Base(info.URL))
+	}
+
+	checksums, err := s.checksums(ctx, info, creds)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	if err := download(ctx, dst, info, creds, checksums, prog); err != nil {
+		r.Err = err
+		return r
+	}
+	r.Path = dst
+	return r
+}
+
+// distinctFiles drops the artifacts which only differ from an earlier one in
+// their repository. They are the same file and would be downloaded to the
+// same path.
+func distinctFiles(artifacts []Artifact) []Artifact {
+	seen := make(map[string]bool)
+	var result []Artifact
+	for _, v := range artifacts {
+		key := strings.Join([]string{v.GroupID, v.ArtifactID, v.Version, v.Classifier, v.Extension}, ":")
+		if seen[key] {
+			lg.Infof("skipping %v, also found in another repository", v)
+			continue
+		}
+		seen[key] = true
+		result = append(result, v)
+	}
+	return result
+}
+
+// checksums returns what a download of info is verified against.
+func (s *GetCommand) checksums(ctx context.Context, info *nexus.ArtifactInfo, creds credentials.Credentials) ([]checksum, error) {
+	if s.NoVerify {
+		return nil, nil
+	}
+	var checksums []checksum
+	if info.Sha1 != "" {
+		checksums = append(checksums, checksum{
+			Algorithm: "sha1",
+			Value:     info.Sha1,
+			Source:    "artifact info",
+		})
+	}
+	if s.Sidecars {
+		sidecars, err := fetchChecksums(ctx, info.URL, creds)
+		if err != nil {
+			return nil, err
+		}
+		checksums = append(checksums, sidecars...)
+	}
+	return checksums, nil
+}
+
+var getCommand GetCommand
+
+// ReposCommand filters by repository format with --repo-format, --format is
+// the global output format.
+type ReposCommand struct {
+	Type   string `long:"type" description:"Only show repositories of this type (hosted, proxy, group...)"`
+	Policy string `long:"policy" description:"Only show repositories with this policy (RELEASE, SNAPSHOT)"`
+	Format string `long:"repo-format" description:"Only show repositories of this format (maven2, maven1...)"`
+}
+
+func (s *ReposCommand) Execute(args []string) error {
+	ctx := rootContext
+	n, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	repos, err := n.RepositoriesContext(ctx)
+	if err != nil {
+		return err
+	}
+	// groups are not returned by the Nexus 2 repositories call
+	if _, ok := n.(*Nexus2x); ok {
+		creds, err := newCredentials()
+		if err != nil {
+			return err
+		}
+		groups, err := fetchGroups(ctx, options.Host, creds)
 		if err != nil {
-			lg.Fatal(err)
+			return err
 		}
-		lg.Infoln(info.URL)
-		err = download(s.Output, info.URL, creds)
+		repos = append(repos, groups...)
+	}
+
+	repos = s.Filter(repos)
+	sort.Sort(ByID(repos))
+
+	if options.Template != "" {
+		tmpl, err := newTemplate(options.Template, n)
 		if err != nil {
-			lg.Fatal(err)
+			return err
+		}
+		for _, v := range repos {
+			if err := tmpl.Execute(os.Stdout, v); err != nil {
+				return err
+			}
+			fmt.Println()
 		}
 		return nil
 	}
+	return writeRepositories(os.Stdout, options.Format, repos)
+}
+
+// Filter returns the repositories matching all given filters, compared case
+// insensitively.
+func (s *ReposCommand) Filter(repos []*nexus.Repository) []*nexus.Repository {
+	var result []*nexus.Repository
+	for _, v := range repos {
+		if s.Type != "" && !strings.EqualFold(v.Type, s.Type) {
+			continue
+		}
+		if s.Policy != "" && !strings.EqualFold(v.Policy, s.Policy) {
+			continue
+		}
+		if s.Format != "" && !strings.EqualFold(v.Format, s.Format) {
+			continue
+		}
+		result = append(result, v)
+	}
+	return result
+}
+
+var reposCommand ReposCommand
+
+type InfoCommand struct {
+	FilterOptions FilterOptions
+}
+
+func (s *InfoCommand) Execute(args []string) error {
+	ctx := rootContext
+	if len(args) < 1 {
+		return usageErrorf("missing artifact coordinates")
+	}
+	n, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
 
+	artifacts, err := resolve(ctx, n, args[0], &s.FilterOptions)
+	if err != nil {
+		return err
+	}
+
+	out, err := newOutput(n, true)
+	if err != nil {
+		return err
+	}
 	for _, v := range artifacts {
-		info, err := n.InfoOf(v.Artifact)
+		info, err := infoOf(ctx, n, v)
 		if err != nil {
-			lg.Fatal(err)
+			return err
 		}
-		lg.Infoln(info.URL)
+		if err := out.Write(v, info, ""); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
 
-		dirname := filepath.Join(v.GroupID, v.ArtifactID, v.Version)
-		filename := filepath.Base(info.URL)
-		err = os.MkdirAll(dirname, 0775)
+// printInfo writes all ArtifactInfo fields as an indented block.
+func printInfo(w io.Writer, info *nexus.ArtifactInfo) {
+	fmt.Fprintln(w, info.Artifact)
+	fmt.Fprintf(w, "  uploader:     %s\n", info.Uploader)
+	fmt.Fprintf(w, "  uploaded:     %s\n", info.Uploaded.Format(time.RFC3339))
+	fmt.Fprintf(w, "  last changed: %s\n", info.LastChanged.Format(time.RFC3339))
+	fmt.Fprintf(w, "  sha1:         %s\n", info.Sha1)
+	fmt.Fprintf(w, "  size:         %s (%d bytes)\n", info.Size, int64(info.Size))
+	fmt.Fprintf(w, "  mime type:    %s\n", info.MimeType)
+	fmt.Fprintf(w, "  url:          %s\n", info.URL)
+}
+
+var infoCommand InfoCommand
+
+// infoOf fetches the ArtifactInfo of an artifact. Nexus 2 reports the upload
+// and change times in milliseconds while the nexus package reads them as
+// seconds, so they are converted here.
+func infoOf(ctx context.Context, n contextClient, a Artifact) (*nexus.ArtifactInfo, error) {
+	info, err := n.InfoOfContext(ctx, a.Artifact)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := n.(*Nexus2x); ok {
+		info.Uploaded = fromMillis(info.Uploaded)
+		info.LastChanged = fromMillis(info.LastChanged)
+	}
+	return info, nil
+}
+
+// fromMillis converts a time in milliseconds read as seconds. A missing time
+// is read as 0 and returned as the zero time.
+func fromMillis(t time.Time) time.Time {
+	ms := t.Unix()
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond))
+}
+
+// resolve looks up all artifacts matching the gav string and applies the
+// filters.
+func resolve(ctx context.Context, n contextClient, gav string, filter *FilterOptions) ([]Artifact, error) {
+	crit, err := ParseGAV(gav)
+	if err != nil {
+		return nil, err
+	}
+	arts, err := n.ArtifactsContext(ctx, crit)
+	if err != nil {
+		return nil, err
+	}
+	var artifacts []Artifact
+	for _, a := range arts {
+		art, err := newArtifact(a)
 		if err != nil {
-			lg.Fatal(err)
+			return nil, err
 		}
+		artifacts = append(artifacts, art)
+	}
+	artifacts = filter.Filter(artifacts)
 
-		err = download(filepath.Join(dirname, filename), info.URL, creds)
+	lg.Infoln(artifacts)
+	if len(artifacts) < 1 {
+		return nil, errNoMatch
+	}
+	return artifacts, nil
+}
+
+// newCredentials returns the configured credentials, including the --header.
+func newCredentials() (credentials.Credentials, error) {
+	creds, err := authCredentials()
+	if err != nil {
+		return nil, err
+	}
+	return withHeader(creds)
+}
+
+// withHeader adds the --header to creds.
+func withHeader(creds credentials.Credentials) (credentials.Credentials, error) {
+	if options.Header == "" {
+		return creds, nil
+	}
+	h, err := parseHeader(options.Header)
+	if err != nil {
+		return nil, err
+	}
+	return combined{creds, h}, nil
+}
+
+// authCredentials returns the credentials for the Authorization header.
+func authCredentials() (credentials.Credentials, error) {
+	switch {
+	case options.MavenServer != "":
+		return mavenCredentials(options.MavenServer)
+	case options.Netrc:
+		path, err := netrcPath()
 		if err != nil {
-			lg.Fatal(err)
+			return nil, err
 		}
-
+		return newNetrc(path)
+	case options.Token != "":
+		return bearer(options.Token), nil
+	case options.Password == "" && options.Host != "":
+		return storedCredentials()
 	}
+	return credentials.BasicAuth(options.User, options.Password), nil
+}
 
-	return nil
+var (
+	storedOnce  sync.Once
+	stored      credentials.Credentials
+	storedError error
+)
 
+// storedCredentials returns the credentials of the credential store for the
+// host, falling back to --user without a password. The store is only asked
+// once.
+func storedCredentials() (credentials.Credentials, error) {
+	storedOnce.Do(func() {
+		stored = credentials.BasicAuth(options.User, "")
+		s, err := newStore()
+		if err != nil {
+			storedError = err
+			return
+		}
+		username, password, err := s.get(options.Host, options.User)
+		if err == errNotStored {
+			lg.V(5).Infoln("no credentials stored for", options.Host, "in", s)
+			return
+		}
+		if err != nil {
+			storedError = err
+			return
+		}
+		stored = credentials.BasicAuth(username, password)
+	})
+	return stored, storedError
 }
 
-var getCommand GetCommand
+// newClient returns a nexus client for the configured host, credentials and
+// API version.
+func newClient(ctx context.Context) (contextClient, error) {
+	creds, err := newCredentials()
+	if err != nil {
+		return nil, err
+	}
+	return clientFor(ctx, creds)
+}
 
+// clientFor returns a nexus client for the configured host and API version
+// using creds.
+func clientFor(ctx context.Context, creds credentials.Credentials) (contextClient, error) {
+	api := options.API
+	if api == "auto" {
+		var err error
+		api, err = detectAPI(ctx, options.Host, creds)
+		if err != nil {
+			return nil, err
+		}
+		lg.V(5).Infoln("detected Nexus API version", api)
+	}
+	if api == "3" {
+		return NewNexus3x(options.Host, creds), nil
+	}
+	return NewNexus2x(options.Host, creds), nil
+}
 
-func searchrepo(q string) ([]Artifact, error) {
+func searchrepo(ctx context.Context, n contextClient, q string) ([]Artifact, error) {
 	var results []Artifact
-	// n := nexus.New(options.Host, credentials.None)
-	n := nexus.New(options.Host, credentials.BasicAuth(options.User, options.Password))
 
 	var RepositoryID string
 	if pos := strings.LastIndex(q, "@"); pos != -1 {
@@ -274,12 +709,12 @@ func searchrepo(q string) ([]Artifact, error) {
 		crit = search.ByKeyword(q)
 	}
 
-	artifacts, err := n.Artifacts(
+	artifacts, err := n.ArtifactsContext(
+		ctx,
 		crit,
 	)
 	if err != nil {
-		fmt.Printf("%v: %v", reflect.TypeOf(err), err)
-		return results, nil
+		return nil, err
 	}
 	for _, a := range artifacts {
 		art, err := newArtifact(a)
@@ -294,7 +729,7 @@ func searchrepo(q string) ([]Artifact, error) {
 // String implements the fmt.Stringer interface, as per Maven docs
 // (http://maven.apache.org/pom.html#Maven_Coordinates).
 
-func ParseGAV(gav string) search.Criteria {
+func ParseGAV(gav string) (search.Criteria, error) {
 	var RepositoryID string
 	if pos := strings.LastIndex(gav, "@"); pos != -1 {
 		RepositoryID = gav[pos+1:]
@@ -326,7 +761,7 @@ func ParseGAV(gav string) search.Criteria {
 			Version:    parts[4],
 		}
 	default:
-		lg.Fatal("invalid string")
+		return nil, usageErrorf("invalid artifact coordinates: %s", gav)
 	}
 
 	if RepositoryID != "" {
@@ -335,9 +770,9 @@ func ParseGAV(gav string) search.Criteria {
 			Criteria:     coords,
 		}
 		lg.Infoln(c)
-		return c
+		return c, nil
 	}
-	return coords
+	return coords, nil
 }
 
 type BySemver []Artifact
@@ -354,6 +789,20 @@ func (v BySemver) Swap(i, j int) {
 	v[i], v[j] = v[j], v[i]
 }
 
+type ByID []*nexus.Repository
+
+func (v ByID) Len() int {
+	return len(v)
+}
+
+func (v ByID) Less(i, j int) bool {
+	return v[i].ID < v[j].ID
+}
+
+func (v ByID) Swap(i, j int) {
+	v[i], v[j] = v[j], v[i]
+}
+
 // GreaterThan compares the semver version number
 func (a *Artifact) GreaterThan(b Artifact) bool {
 	return a.v.GreaterThan(b.v)
@@ -387,26 +836,56 @@ func newArtifact(a *nexus.Artifact) (Artifact, error) {
 	}, nil
 }
 
-func download(dst, url string, creds credentials.Credentials) error {
-	out, err := os.Create(dst)
+// fetchGroups returns the repository groups, which the nexus client leaves out
+// of Repositories().
+func fetchGroups(ctx context.Context, host string, creds credentials.Credentials) ([]*nexus.Repository, error) {
+	url, err := util.BuildFullURL(host, "service/local/repo_groups", nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer out.Close()
-	req, err := http.NewRequest("GET", url, bytes.NewBufferString(url))
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		lg.Fatal(err)
+		return nil, err
 	}
 	creds.Sign(req)
+	req.Header.Add("Accept", "application/xml")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpClient().Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
-	_, err = io.Copy(out, resp.Body)
-	if err != nil {
-		return err
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return nil, &credentials.Error{URL: url, Credentials: creds}
+	case resp.StatusCode != http.StatusOK:
+		return nil, nexus.Error{
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Message:    fmt.Sprintf("Error (%v) from %v", resp.Status, url),
+		}
 	}
-	return nil
+
+	var payload struct {
+		Data []struct {
+			ID     string `xml:"id"`
+			Name   string `xml:"name"`
+			Format string `xml:"format"`
+		} `xml:"data>repo-group-list-item"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	var groups []*nexus.Repository
+	for _, v := range payload.Data {
+		groups = append(groups, &nexus.Repository{
+			ID:     v.ID,
+			Name:   v.Name,
+			Type:   "group",
+			Format: v.Format,
+		})
+	}
+	return groups, nil
 }
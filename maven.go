@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hanjos/nexus/credentials"
+)
+
+// mavenServer signs requests with the credentials of a <server> entry in the
+// Maven settings.xml. It also implements the fmt.Stringer interface.
+type mavenServer struct {
+	ID       string
+	Username string
+	Password string
+}
+
+func (c mavenServer) Sign(request *http.Request) {
+	credentials.BasicAuth(c.Username, c.Password).Sign(request)
+}
+
+func (c mavenServer) String() string {
+	return "MavenServer(" + c.ID + ", " + c.Username + ", ***)"
+}
+
+// mavenDir returns the Maven user directory, ~/.m2.
+func mavenDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".m2"), nil
+}
+
+// mavenCredentials returns the credentials of server id in
+// ~/.m2/settings.xml, decrypting the password with the master password of
+// ~/.m2/settings-security.xml when it is encrypted.
+func mavenCredentials(id string) (credentials.Credentials, error) {
+	dir, err := mavenDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "settings.xml")
+	var settings struct {
+		Servers []struct {
+			ID       string `xml:"id"`
+			Username string `xml:"username"`
+			Password string `xml:"password"`
+		} `xml:"servers>server"`
+	}
+	if err := readXML(path, &settings); err != nil {
+		return nil, err
+	}
+	for _, s := range settings.Servers {
+		if strings.TrimSpace(s.ID) != id {
+			continue
+		}
+		c := mavenServer{
+			ID:       id,
+			Username: interpolateEnv(strings.TrimSpace(s.Username)),
+			Password: interpolateEnv(strings.TrimSpace(s.Password)),
+		}
+		if isEncrypted(c.Password) {
+			master, err := mavenMasterPassword(filepath.Join(dir, "settings-security.xml"))
+			if err != nil {
+				return nil, err
+			}
+			c.Password, err = decryptMaven(c.Password, master)
+			if err != nil {
+				return nil, fmt.Errorf("server %s in %s: %v", id, path, err)
+			}
+		}
+		return c, nil
+	}
+	return nil, fmt.Errorf("no server %s in %s", id, path)
+}
+
+func readXML(path string, v interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := xml.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+var envExpression = regexp.MustCompile(`\$\{env\.([^}]+)\}`)
+
+// interpolateEnv replaces ${env.NAME} with the value of the environment
+// variable NAME, like Maven does when reading settings.xml.
+func interpolateEnv(s string) string {
+	return envExpression.ReplaceAllStringFunc(s, func(m string) string {
+		return os.Getenv(envExpression.FindStringSubmatch(m)[1])
+	})
+}
+
+// mavenMasterPassword returns the decrypted master password of a
+// settings-security.xml, following a <relocation> to another file.
+func mavenMasterPassword(path string) (string, error) {
+	var security struct {
+		Master     string `xml:"master"`
+		Relocation string `xml:"relocation"`
+	}
+	if err := readXML(path, &security); err != nil {
+		return "", err
+	}
+	if r := strings.TrimSpace(security.Relocation); r != "" {
+		return mavenMasterPassword(r)
+	}
+	master := strings.TrimSpace(security.Master)
+	if !isEncrypted(master) {
+		return "", fmt.Errorf("%s: no encrypted master password", path)
+	}
+	// the master password is encrypted with a well known password
+	return decryptMaven(master, "settings.security")
+}
+
+// isEncrypted returns true if s contains an encrypted {...} value. Maven
+// allows text around the braces.
+func isEncrypted(s string) bool {
+	start, end := strings.Index(s, "{"), strings.LastIndex(s, "}")
+	return start != -1 && end > start
+}
+
+// decryptMaven decrypts a {...} value the way plexus-cipher encrypts it: the
+// base64 data is an 8 byte salt, the padding length and the AES/CBC encrypted
+// text followed by the padding. Key and IV are the SHA-256 of the password and
+// salt.
+func decryptMaven(s, password string) (string, error) {
+	s = s[strings.Index(s, "{")+1 : strings.LastIndex(s, "}")]
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted password: %v", err)
+	}
+	if len(data) < 9 {
+		return "", errors.New("invalid encrypted password")
+	}
+	salt, padLen := data[:8], int(data[8])
+	if len(data)-padLen < 9 {
+		return "", errors.New("invalid encrypted password")
+	}
+	text := data[9 : len(data)-padLen]
+	if len(text) == 0 || len(text)%aes.BlockSize != 0 {
+		return "", errors.New("invalid encrypted password")
+	}
+
+	keyAndIV := sha256.Sum256(append([]byte(password), salt...))
+	block, err := aes.NewCipher(keyAndIV[:16])
+	if err != nil {
+		return "", err
+	}
+	plain := make([]byte, len(text))
+	cipher.NewCBCDecrypter(block, keyAndIV[16:]).CryptBlocks(plain, text)
+
+	// PKCS#5 padding
+	n := int(plain[len(plain)-1])
+	if n == 0 || n > aes.BlockSize || !bytes.Equal(plain[len(plain)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return "", errors.New("could not decrypt password, wrong master password?")
+	}
+	return string(plain[:len(plain)-n]), nil
+}
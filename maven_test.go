@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The encrypted values were made with openssl following the PBECipher of
+// plexus-cipher 1.7, which mvn --encrypt-master-password and
+// --encrypt-password use.
+const (
+	encryptedMaster   = "{AQIDBAUGBwgHCHn9nHMs+8mwQpAZR6h3K+Qnm/pY59pL+LLd1JWppsWgoaKjpKWm}" // "master of puppets"
+	encryptedPassword = "{obLD1OX2BxgHmBvEuvArfSkAkIqyTJ7wL6ChoqOkpaY=}"                     // "deploy-secret"
+)
+
+func TestDecryptMaven(t *testing.T) {
+	tests := []struct {
+		encrypted, password, want string
+	}{
+		{encryptedMaster, "settings.security", "master of puppets"},
+		{encryptedPassword, "master of puppets", "deploy-secret"},
+		{"Deployment password " + encryptedPassword + " (changed 2016)", "master of puppets", "deploy-secret"},
+	}
+	for _, tt := range tests {
+		got, err := decryptMaven(tt.encrypted, tt.password)
+		if err != nil {
+			t.Errorf("decryptMaven(%q): %v", tt.encrypted, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("decryptMaven(%q) = %q, want %q", tt.encrypted, got, tt.want)
+		}
+	}
+
+	for _, encrypted := range []string{"{}", "{not base64}", "{AQIDBAUGBwgH}", encryptedPassword} {
+		if got, err := decryptMaven(encrypted, "wrong"); err == nil {
+			t.Errorf("decryptMaven(%q) with a wrong password = %q, want an error", encrypted, got)
+		}
+	}
+}
+
+func TestMavenCredentials(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("NEXUS_USER", "deployer")
+
+	write := func(path, content string) {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(home, ".m2", "settings.xml"), `<settings>
+  <servers>
+    <server>
+      <id>plain</id>
+      <username>reader</username>
+      <password>${env.NEXUS_USER}-password</password>
+    </server>
+    <server>
+      <id>nexus</id>
+      <username>${env.NEXUS_USER}</username>
+      <password>`+encryptedPassword+`</password>
+    </server>
+  </servers>
+</settings>`)
+	relocated := filepath.Join(home, "usb", "settings-security.xml")
+	write(filepath.Join(home, ".m2", "settings-security.xml"), `<settingsSecurity>
+  <relocation>`+relocated+`</relocation>
+</settingsSecurity>`)
+	write(relocated, `<settingsSecurity>
+  <master>`+encryptedMaster+`</master>
+</settingsSecurity>`)
+
+	tests := []struct {
+		id   string
+		want mavenServer
+	}{
+		{"plain", mavenServer{ID: "plain", Username: "reader", Password: "deployer-password"}},
+		{"nexus", mavenServer{ID: "nexus", Username: "deployer", Password: "deploy-secret"}},
+	}
+	for _, tt := range tests {
+		creds, err := mavenCredentials(tt.id)
+		if err != nil {
+			t.Errorf("mavenCredentials(%q): %v", tt.id, err)
+			continue
+		}
+		if creds != tt.want {
+			t.Errorf("mavenCredentials(%q) = %#v, want %#v", tt.id, creds, tt.want)
+		}
+	}
+	if _, err := mavenCredentials("missing"); err == nil {
+		t.Error("mavenCredentials of a missing server succeeded")
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+
+	"github.com/hanjos/nexus"
+	"github.com/hanjos/nexus/credentials"
+	"github.com/hanjos/nexus/search"
+	"github.com/hanjos/nexus/util"
+)
+
+// contextClient is a nexus.Client which can also send its requests with a
+// context, cancelling them when it is done.
+type contextClient interface {
+	nexus.Client
+
+	// Like Artifacts, but the requests are cancelled when ctx is done.
+	ArtifactsContext(ctx context.Context, criteria search.Criteria) ([]*nexus.Artifact, error)
+
+	// Like Repositories, but the requests are cancelled when ctx is done.
+	RepositoriesContext(ctx context.Context) ([]*nexus.Repository, error)
+
+	// Like InfoOf, but the requests are cancelled when ctx is done.
+	InfoOfContext(ctx context.Context, artifact *nexus.Artifact) (*nexus.ArtifactInfo, error)
+}
+
+// Nexus2x is the Nexus 2 client of the nexus package, which predates
+// contexts, with the contextClient methods added. It is kept unchanged in
+// vendor, so they are built on top of it here.
+type Nexus2x struct {
+	*nexus.Nexus2x
+}
+
+// NewNexus2x creates a new Nexus 2 client, like nexus.New but with the shared
+// HTTP client.
+func NewNexus2x(url string, c credentials.Credentials) *Nexus2x {
+	return &Nexus2x{&nexus.Nexus2x{
+		URL:         url,
+		Credentials: credentials.OrZero(c),
+		HTTPClient:  httpClient(),
+	}}
+}
+
+// withContext returns a copy of the nexus package client which sends its
+// requests with ctx.
+func (n Nexus2x) withContext(ctx context.Context) *nexus.Nexus2x {
+	c := *n.Nexus2x
+	client := *c.HTTPClient
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	client.Transport = contextTransport{RoundTripper: transport, ctx: ctx}
+	c.HTTPClient = &client
+	return &c
+}
+
+// contextTransport sends requests with ctx, for clients which create them
+// without one.
+type contextTransport struct {
+	http.RoundTripper
+	ctx context.Context
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.RoundTripper.RoundTrip(req.WithContext(t.ctx))
+}
+
+// ArtifactsContext implements the contextClient interface. Searches of whole
+// repositories are done here rather than in the nexus package, which leaves
+// its goroutines blocked when one of the searches fails.
+func (n Nexus2x) ArtifactsContext(ctx context.Context, criteria search.Criteria) ([]*nexus.Artifact, error) {
+	params := search.OrZero(criteria).Parameters()
+	repositoryID, inRepository := params["repositoryId"]
+	switch {
+	case len(params) == 0:
+		return n.allArtifacts(ctx)
+	case len(params) == 1 && inRepository:
+		return n.artifactsIn(ctx, repositoryID)
+	}
+	return n.withContext(ctx).Artifacts(criteria)
+}
+
+// allArtifacts returns every artifact in every repository.
+func (n Nexus2x) allArtifacts(ctx context.Context) ([]*nexus.Artifact, error) {
+	repos, err := n.RepositoriesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(repos))
+	for i, repo := range repos {
+		ids[i] = repo.ID
+	}
+	return searchEach(ctx, ids, n.artifactsIn)
+}
+
+// artifactsIn returns every artifact in a repository. Nexus 2 does not search
+// for everything, so like the nexus package this searches for the group IDs
+// starting with each of its top level directories.
+func (n Nexus2x) artifactsIn(ctx context.Context, repositoryID string) ([]*nexus.Artifact, error) {
+	u, err := util.CleanSlashes(n.URL + "/service/local/repositories/" + repositoryID + "/content/")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := doRequest(ctx, "GET", u, nil, 0, n.Credentials)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var payload struct {
+		Items []struct {
+			Leaf bool   `xml:"leaf"`
+			Text string `xml:"text"`
+		} `xml:"data>content-item"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, v := range payload.Items {
+		if !v.Leaf {
+			dirs = append(dirs, v.Text)
+		}
+	}
+
+	return searchEach(ctx, dirs, func(ctx context.Context, dir string) ([]*nexus.Artifact, error) {
+		return n.withContext(ctx).Artifacts(search.InRepository{
+			RepositoryID: repositoryID,
+			Criteria:     search.ByCoordinates{GroupID: dir + "*"},
+		})
+	})
+}
+
+// searchEach calls query for each element of data concurrently and returns
+// the artifacts found without duplicates. The first error cancels the
+// remaining queries.
+func searchEach(ctx context.Context, data []string, query func(context.Context, string) ([]*nexus.Artifact, error)) ([]*nexus.Artifact, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		artifacts []*nexus.Artifact
+		err       error
+	}
+	// buffered, so that no goroutine is left blocked after an error
+	results := make(chan result, len(data))
+	for _, datum := range data {
+		go func(datum string) {
+			a, err := query(ctx, datum)
+			results <- result{a, err}
+		}(datum)
+	}
+
+	artifacts := []*nexus.Artifact{}
+	seen := make(map[string]bool)
+	for range data {
+		r := <-results
+		if r.err != nil {
+			return nil, r.err
+		}
+		for _, a := range r.artifacts {
+			if !seen[a.String()] {
+				seen[a.String()] = true
+				artifacts = append(artifacts, a)
+			}
+		}
+	}
+	return artifacts, nil
+}
+
+// RepositoriesContext implements the contextClient interface.
+func (n Nexus2x) RepositoriesContext(ctx context.Context) ([]*nexus.Repository, error) {
+	return n.withContext(ctx).Repositories()
+}
+
+// InfoOfContext implements the contextClient interface.
+func (n Nexus2x) InfoOfContext(ctx context.Context, artifact *nexus.Artifact) (*nexus.ArtifactInfo, error) {
+	return n.withContext(ctx).InfoOf(artifact)
+}
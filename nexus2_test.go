@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hanjos/nexus"
+)
+
+func TestSearchEach(t *testing.T) {
+	ctx := context.Background()
+	found := map[string][]*nexus.Artifact{
+		"com": {{GroupID: "com.example", ArtifactID: "lib", Version: "1.0", RepositoryID: "releases"}},
+		"co":  {{GroupID: "com.example", ArtifactID: "lib", Version: "1.0", RepositoryID: "releases"}},
+		"org": {{GroupID: "org.example", ArtifactID: "app", Version: "2.0", RepositoryID: "releases"}},
+	}
+	arts, err := searchEach(ctx, []string{"com", "co", "org"}, func(ctx context.Context, dir string) ([]*nexus.Artifact, error) {
+		return found[dir], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, a := range arts {
+		got = append(got, a.String())
+	}
+	if len(got) != 2 {
+		t.Errorf("got %v, want the two distinct artifacts", got)
+	}
+
+	// the failing query cancels the others, which must still finish
+	errFailed := errors.New("failed")
+	done := make(chan string, 3)
+	_, err = searchEach(ctx, []string{"fail", "a", "b"}, func(ctx context.Context, datum string) ([]*nexus.Artifact, error) {
+		defer func() { done <- datum }()
+		if datum == "fail" {
+			return nil, errFailed
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err != errFailed {
+		t.Errorf("got error %v, want %v", err, errFailed)
+	}
+	finished := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		finished[<-done] = true
+	}
+	if want := map[string]bool{"fail": true, "a": true, "b": true}; !reflect.DeepEqual(finished, want) {
+		t.Errorf("finished %v, want all queries", finished)
+	}
+}
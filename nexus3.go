@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hanjos/nexus"
+	"github.com/hanjos/nexus/credentials"
+	"github.com/hanjos/nexus/search"
+	"github.com/hanjos/nexus/util"
+	"github.com/thomasf/lg"
+)
+
+// Nexus3x represents a Nexus Repository Manager 3 instance, using its REST
+// API. It implements the contextClient interface.
+type Nexus3x struct {
+	URL         string                  // e.g. http://somewhere.com:8081
+	Credentials credentials.Credentials // e.g. credentials.BasicAuth("u", "p")
+	HTTPClient  *http.Client            // the network client
+}
+
+// NewNexus3x creates a new Nexus 3 client.
+func NewNexus3x(url string, c credentials.Credentials) *Nexus3x {
+	return &Nexus3x{
+		URL:         url,
+		Credentials: credentials.OrZero(c),
+		HTTPClient:  httpClient(),
+	}
+}
+
+// detectAPI returns "3" if the server at url answers the Nexus 3 REST API and
+// "2" otherwise.
+func detectAPI(ctx context.Context, host string, c credentials.Credentials) (string, error) {
+	u, err := util.BuildFullURL(host, "service/rest/v1/status", nil)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return "", err
+	}
+	credentials.OrZero(c).Sign(req)
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK || strings.HasPrefix(resp.Header.Get("Server"), "Nexus/3") {
+		return "3", nil
+	}
+	return "2", nil
+}
+
+// does the actual legwork, going to Nexus and decoding the JSON response into
+// v.
+func (n Nexus3x) fetch(ctx context.Context, path string, query url.Values, v interface{}) error {
+	fullURL, err := util.CleanSlashes(n.URL + "/" + path)
+	if err != nil {
+		return err
+	}
+	if len(query) > 0 {
+		fullURL += "?" + query.Encode()
+	}
+
+	get, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
+	if err != nil {
+		return err
+	}
+	n.Credentials.Sign(get)
+	get.Header.Add("Accept", "application/json")
+
+	resp, err := n.HTTPClient.Do(get)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	status := resp.StatusCode
+	switch {
+	case status == http.StatusUnauthorized:
+		return &credentials.Error{URL: fullURL, Credentials: n.Credentials}
+	case 400 <= status && status < 600:
+		return nexus.Error{
+			URL:        fullURL,
+			StatusCode: status,
+			Status:     resp.Status,
+			Message:    fmt.Sprintf("Error (%v) from %v", resp.Status, fullURL),
+		}
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// nexus3Asset is an asset as returned by the search and components APIs.
+type nexus3Asset struct {
+	ID           string            `json:"id"`
+	DownloadURL  string            `json:"downloadUrl"`
+	Path         string            `json:"path"`
+	Repository   string            `json:"repository"`
+	Format       string            `json:"format"`
+	Checksum     map[string]string `json:"checksum"`
+	ContentType  string            `json:"contentType"`
+	LastModified time.Time         `json:"lastModified"`
+	BlobCreated  time.Time         `json:"blobCreated"`
+	Uploader     string            `json:"uploader"`
+	FileSize     int64             `json:"fileSize"`
+	Maven2       struct {
+		GroupID     string `json:"groupId"`
+		ArtifactID  string `json:"artifactId"`
+		Version     string `json:"version"`
+		BaseVersion string `json:"baseVersion"`
+		Classifier  string `json:"classifier"`
+		Extension   string `json:"extension"`
+	} `json:"maven2"`
+}
+
+// artifact returns the asset as an artifact, or nil if it isn't a maven
+// artifact (e.g. metadata or a checksum file).
+func (a nexus3Asset) artifact() *nexus.Artifact {
+	m := a.Maven2
+	if m.ArtifactID == "" || m.Extension == "" {
+		return nil
+	}
+	for _, ext := range []string{".sha1", ".sha256", ".sha512", ".md5", ".asc"} {
+		if strings.HasSuffix(m.Extension, ext) {
+			return nil
+		}
+	}
+	version := m.BaseVersion
+	if version == "" {
+		version = m.Version
+	}
+	return &nexus.Artifact{
+		GroupID:      m.GroupID,
+		ArtifactID:   m.ArtifactID,
+		Version:      version,
+		Classifier:   m.Classifier,
+		Extension:    m.Extension,
+		RepositoryID: a.Repository,
+	}
+}
+
+type nexus3Components struct {
+	Items []struct {
+		Assets []nexus3Asset `json:"assets"`
+	} `json:"items"`
+	ContinuationToken string `json:"continuationToken"`
+}
+
+type nexus3Assets struct {
+	Items             []nexus3Asset `json:"items"`
+	ContinuationToken string        `json:"continuationToken"`
+}
+
+// Artifacts implements the nexus.Client interface. search.All searches every
+// repository, and a search only by repository lists the repository's
+// components. Class name searches aren't supported by Nexus 3.
+func (n Nexus3x) Artifacts(criteria search.Criteria) ([]*nexus.Artifact, error) {
+	return n.ArtifactsContext(context.Background(), criteria)
+}
+
+// ArtifactsContext implements the contextClient interface.
+func (n Nexus3x) ArtifactsContext(ctx context.Context, criteria search.Criteria) ([]*nexus.Artifact, error) {
+	params := search.OrZero(criteria).Parameters()
+
+	if len(params) == 0 { // full search
+		repos, err := n.RepositoriesContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var result []*nexus.Artifact
+		for _, r := range repos {
+			if r.Type == "group" || !strings.HasPrefix(r.Format, "maven") {
+				continue
+			}
+			artifacts, err := n.components(ctx, r.ID)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, artifacts...)
+		}
+		return result, nil
+	}
+
+	if len(params) == 1 {
+		if repoID, ok := params["repositoryId"]; ok { // all in repo search
+			return n.components(ctx, repoID)
+		}
+	}
+
+	query := url.Values{"format": {"maven2"}}
+	for k, v := range params {
+		switch k {
+		case "g":
+			query.Set("group", v)
+		case "a":
+			query.Set("name", v)
+		case "v":
+			if strings.HasSuffix(v, "SNAPSHOT") {
+				query.Set("maven.baseVersion", v)
+			} else {
+				query.Set("version", v)
+			}
+		case "p":
+			query.Set("maven.extension", v)
+		case "c":
+			query.Set("maven.classifier", v)
+		case "q":
+			query.Set("q", v)
+		case "sha1":
+			query.Set("sha1", v)
+		case "repositoryId":
+			query.Set("repository", v)
+		default:
+			return nil, fmt.Errorf("search parameter %s is not supported by Nexus 3", k)
+		}
+	}
+	return n.search(ctx, query)
+}
+
+// returns the artifacts of all components matching query.
+func (n Nexus3x) search(ctx context.Context, query url.Values) ([]*nexus.Artifact, error) {
+	var result []*nexus.Artifact
+	seen := make(map[string]bool)
+	for {
+		var payload nexus3Components
+		if err := n.fetch(ctx, "service/rest/v1/search", query, &payload); err != nil {
+			return nil, err
+		}
+		for _, c := range payload.Items {
+			result = appendAssets(result, seen, c.Assets)
+		}
+		if payload.ContinuationToken == "" {
+			return result, nil
+		}
+		query.Set("continuationToken", payload.ContinuationToken)
+	}
+}
+
+// returns the artifacts of all components in a repository.
+func (n Nexus3x) components(ctx context.Context, repositoryID string) ([]*nexus.Artifact, error) {
+	var result []*nexus.Artifact
+	seen := make(map[string]bool)
+	query := url.Values{"repository": {repositoryID}}
+	for {
+		var payload nexus3Components
+		if err := n.fetch(ctx, "service/rest/v1/components", query, &payload); err != nil {
+			return nil, err
+		}
+		for _, c := range payload.Items {
+			result = appendAssets(result, seen, c.Assets)
+		}
+		if payload.ContinuationToken == "" {
+			return result, nil
+		}
+		query.Set("continuationToken", payload.ContinuationToken)
+	}
+}
+
+// appendAssets appends the maven artifacts among assets which aren't seen
+// yet. Snapshots have one asset per build, which all share the same artifact.
+func appendAssets(artifacts []*nexus.Artifact, seen map[string]bool, assets []nexus3Asset) []*nexus.Artifact {
+	for _, asset := range assets {
+		a := asset.artifact()
+		if a == nil || seen[a.String()] {
+			continue
+		}
+		seen[a.String()] = true
+		artifacts = append(artifacts, a)
+	}
+	return artifacts
+}
+
+// componentIDs returns the IDs of the components of a version, which for
+// snapshots is one component per build.
+func (n Nexus3x) componentIDs(ctx context.Context, repositoryID, groupID, artifactID, version string) ([]string, error) {
+	query := url.Values{
+		"format":     {"maven2"},
+		"repository": {repositoryID},
+		"group":      {groupID},
+		"name":       {artifactID},
+	}
+	if strings.HasSuffix(version, "SNAPSHOT") {
+		query.Set("maven.baseVersion", version)
+	} else {
+		query.Set("version", version)
+	}
+
+	var ids []string
+	for {
+		var payload struct {
+			Items []struct {
+				ID string `json:"id"`
+			} `json:"items"`
+			ContinuationToken string `json:"continuationToken"`
+		}
+		if err := n.fetch(ctx, "service/rest/v1/search", query, &payload); err != nil {
+			return nil, err
+		}
+		for _, c := range payload.Items {
+			ids = append(ids, c.ID)
+		}
+		if payload.ContinuationToken == "" {
+			return ids, nil
+		}
+		query.Set("continuationToken", payload.ContinuationToken)
+	}
+}
+
+// Repositories implements the nexus.Client interface. Unlike Nexus 2, groups
+// are included.
+func (n Nexus3x) Repositories() ([]*nexus.Repository, error) {
+	return n.RepositoriesContext(context.Background())
+}
+
+// RepositoriesContext implements the contextClient interface. The
+// repositories are those the user may browse, the version policy is added
+// from the repository settings if the user may read them too.
+func (n Nexus3x) RepositoriesContext(ctx context.Context) ([]*nexus.Repository, error) {
+	type repository struct {
+		Name   string `json:"name"`
+		Format string `json:"format"`
+		Type   string `json:"type"`
+		Maven  *struct {
+			VersionPolicy string `json:"versionPolicy"`
+		} `json:"maven"`
+		Proxy *struct {
+			RemoteURL string `json:"remoteUrl"`
+		} `json:"proxy"`
+		Attributes struct {
+			Proxy *struct {
+				RemoteURL string `json:"remoteUrl"`
+			} `json:"proxy"`
+		} `json:"attributes"`
+	}
+
+	var payload []repository
+	if err := n.fetch(ctx, "service/rest/v1/repositories", nil, &payload); err != nil {
+		return nil, err
+	}
+
+	// repositorySettings is an administration API, only available in newer
+	// versions and usually not to everyone
+	settings := make(map[string]repository)
+	var all []repository
+	if err := n.fetch(ctx, "service/rest/v1/repositorySettings", nil, &all); err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		lg.V(5).Infoln("no repository settings, the version policies are unknown:", err)
+	}
+	for _, v := range all {
+		settings[v.Name] = v
+	}
+
+	var repos []*nexus.Repository
+	for _, v := range payload {
+		r := &nexus.Repository{
+			ID:     v.Name,
+			Name:   v.Name,
+			Type:   v.Type,
+			Format: v.Format,
+		}
+		if v.Maven == nil {
+			v.Maven = settings[v.Name].Maven
+		}
+		if v.Maven != nil {
+			r.Policy = v.Maven.VersionPolicy
+		}
+		switch {
+		case v.Proxy != nil:
+			r.RemoteURI = v.Proxy.RemoteURL
+		case v.Attributes.Proxy != nil:
+			r.RemoteURI = v.Attributes.Proxy.RemoteURL
+		case settings[v.Name].Proxy != nil:
+			r.RemoteURI = settings[v.Name].Proxy.RemoteURL
+		}
+		repos = append(repos, r)
+	}
+	return repos, nil
+}
+
+// InfoOf implements the nexus.Client interface. For snapshots the most
+// recently modified build is used.
+func (n Nexus3x) InfoOf(artifact *nexus.Artifact) (*nexus.ArtifactInfo, error) {
+	return n.InfoOfContext(context.Background(), artifact)
+}
+
+// InfoOfContext implements the contextClient interface.
+func (n Nexus3x) InfoOfContext(ctx context.Context, artifact *nexus.Artifact) (*nexus.ArtifactInfo, error) {
+	query := url.Values{
+		"format":          {"maven2"},
+		"repository":      {artifact.RepositoryID},
+		"group":           {artifact.GroupID},
+		"name":            {artifact.ArtifactID},
+		"maven.extension": {artifact.Extension},
+	}
+	if strings.HasSuffix(artifact.Version, "SNAPSHOT") {
+		query.Set("maven.baseVersion", artifact.Version)
+	} else {
+		query.Set("version", artifact.Version)
+	}
+	if artifact.Classifier != "" {
+		query.Set("maven.classifier", artifact.Classifier)
+	}
+
+	var found *nexus3Asset
+	for {
+		var payload nexus3Assets
+		if err := n.fetch(ctx, "service/rest/v1/search/assets", query, &payload); err != nil {
+			return nil, err
+		}
+		for i, asset := range payload.Items {
+			// an empty classifier doesn't filter the search
+			if asset.Maven2.Classifier != artifact.Classifier || asset.Maven2.Extension != artifact.Extension {
+				continue
+			}
+			if found == nil || asset.LastModified.After(found.LastModified) {
+				found = &payload.Items[i]
+			}
+		}
+		if payload.ContinuationToken == "" {
+			break
+		}
+		query.Set("continuationToken", payload.ContinuationToken)
+	}
+	if found == nil {
+		return nil, nexus.Error{
+			URL:        n.URL,
+			StatusCode: http.StatusNotFound,
+			Status:     "404 Not Found",
+			Message:    fmt.Sprintf("%v not found in %v", artifact, n.URL),
+		}
+	}
+
+	return &nexus.ArtifactInfo{
+		Artifact:    artifact,
+		Uploader:    found.Uploader,
+		Uploaded:    found.BlobCreated,
+		LastChanged: found.LastModified,
+		Sha1:        found.Checksum["sha1"],
+		Size:        util.ByteSize(found.FileSize),
+		MimeType:    found.ContentType,
+		URL:         found.DownloadURL,
+	}, nil
+}
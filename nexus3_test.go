@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/hanjos/nexus/credentials"
+)
+
+func TestNexus3Repositories(t *testing.T) {
+	settingsStatus := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/service/rest/v1/repositories":
+			fmt.Fprint(w, `[
+				{"name": "releases", "format": "maven2", "type": "hosted"},
+				{"name": "central", "format": "maven2", "type": "proxy", "attributes": {"proxy": {"remoteUrl": "https://repo1.maven.org/maven2/"}}}
+			]`)
+		case "/service/rest/v1/repositorySettings":
+			w.WriteHeader(settingsStatus)
+			if settingsStatus == http.StatusOK {
+				// includes a repository the user may not browse
+				fmt.Fprint(w, `[
+					{"name": "releases", "format": "maven2", "type": "hosted", "maven": {"versionPolicy": "RELEASE"}},
+					{"name": "secret", "format": "maven2", "type": "hosted", "maven": {"versionPolicy": "RELEASE"}}
+				]`)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	n := &Nexus3x{URL: srv.URL, Credentials: credentials.None, HTTPClient: srv.Client()}
+
+	for _, tt := range []struct {
+		settingsStatus int
+		want           []string
+	}{
+		{http.StatusOK, []string{"releases hosted RELEASE ", "central proxy  https://repo1.maven.org/maven2/"}},
+		{http.StatusForbidden, []string{"releases hosted  ", "central proxy  https://repo1.maven.org/maven2/"}},
+		{http.StatusNotFound, []string{"releases hosted  ", "central proxy  https://repo1.maven.org/maven2/"}},
+	} {
+		settingsStatus = tt.settingsStatus
+		repos, err := n.Repositories()
+		if err != nil {
+			t.Fatalf("settings %d: %v", tt.settingsStatus, err)
+		}
+		var got []string
+		for _, r := range repos {
+			got = append(got, fmt.Sprintf("%s %s %s %s", r.ID, r.Type, r.Policy, r.RemoteURI))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("settings %d: got %q, want %q", tt.settingsStatus, got, tt.want)
+		}
+	}
+}
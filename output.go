@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/hanjos/nexus"
+)
+
+// artifactRecord is the machine readable form of an Artifact, optionally
+// including the ArtifactInfo fields and the local path of a download.
+type artifactRecord struct {
+	GroupID      string `json:"groupId"`
+	ArtifactID   string `json:"artifactId"`
+	Version      string `json:"version"`
+	Classifier   string `json:"classifier"`
+	Extension    string `json:"extension"`
+	RepositoryID string `json:"repositoryId"`
+	Semver       string `json:"semver,omitempty"`
+
+	Uploader    string     `json:"uploader,omitempty"`
+	Uploaded    *time.Time `json:"uploaded,omitempty"`
+	LastChanged *time.Time `json:"lastChanged,omitempty"`
+	Sha1        string     `json:"sha1,omitempty"`
+	Size        *int64     `json:"size,omitempty"`
+	MimeType    string     `json:"mimeType,omitempty"`
+	URL         string     `json:"url,omitempty"`
+
+	Path string `json:"path,omitempty"`
+}
+
+func newArtifactRecord(a Artifact, info *nexus.ArtifactInfo, path string) artifactRecord {
+	r := artifactRecord{
+		GroupID:      a.GroupID,
+		ArtifactID:   a.ArtifactID,
+		Version:      a.Version,
+		Classifier:   a.Classifier,
+		Extension:    a.Extension,
+		RepositoryID: a.RepositoryID,
+		Path:         path,
+	}
+	if a.v != nil {
+		r.Semver = a.v.String()
+	}
+	if info != nil {
+		size := int64(info.Size)
+		r.Uploader = info.Uploader
+		r.Uploaded = &info.Uploaded
+		r.LastChanged = &info.LastChanged
+		r.Sha1 = info.Sha1
+		r.Size = &size
+		r.MimeType = info.MimeType
+		r.URL = info.URL
+	}
+	return r
+}
+
+var (
+	artifactColumns = []string{"groupId", "artifactId", "version", "classifier", "extension", "repositoryId", "semver"}
+	infoColumns     = []string{"uploader", "uploaded", "lastChanged", "sha1", "size", "mimeType", "url", "path"}
+)
+
+func (r artifactRecord) row(withInfo bool) []string {
+	row := []string{r.GroupID, r.ArtifactID, r.Version, r.Classifier, r.Extension, r.RepositoryID, r.Semver}
+	if !withInfo {
+		return row
+	}
+	var uploaded, lastChanged, size string
+	if r.Uploaded != nil {
+		uploaded = r.Uploaded.Format(time.RFC3339)
+	}
+	if r.LastChanged != nil {
+		lastChanged = r.LastChanged.Format(time.RFC3339)
+	}
+	if r.Size != nil {
+		size = fmt.Sprint(*r.Size)
+	}
+	return append(row, r.Uploader, uploaded, lastChanged, r.Sha1, size, r.MimeType, r.URL, r.Path)
+}
+
+// Output formats accepted by --format.
+const (
+	formatText  = "text"
+	formatJSON  = "json"
+	formatJSONL = "jsonl"
+	formatCSV   = "csv"
+	formatTSV   = "tsv"
+
+	// formatTemplate is used when --template is given.
+	formatTemplate = "template"
+)
+
+// artifactWriter writes artifacts in one of the output formats. Flush must be
+// called after the last Write.
+type artifactWriter struct {
+	format   string
+	withInfo bool // include the ArtifactInfo columns in csv/tsv output
+	w        io.Writer
+	csv      *csv.Writer
+	records  []artifactRecord // buffered json output
+	header   bool             // csv/tsv header written
+	tmpl     *template.Template
+	client   contextClient // used by templates
+}
+
+// newOutput creates an artifactWriter for stdout from the global --format
+// and --template options.
+func newOutput(n contextClient, withInfo bool) (*artifactWriter, error) {
+	if options.Template == "" {
+		return newArtifactWriter(os.Stdout, options.Format, withInfo), nil
+	}
+	tmpl, err := newTemplate(options.Template, n)
+	if err != nil {
+		return nil, err
+	}
+	aw := newArtifactWriter(os.Stdout, formatTemplate, withInfo)
+	aw.tmpl = tmpl
+	aw.client = n
+	return aw, nil
+}
+
+func newArtifactWriter(w io.Writer, format string, withInfo bool) *artifactWriter {
+	aw := &artifactWriter{
+		format:   format,
+		withInfo: withInfo,
+		w:        w,
+	}
+	switch format {
+	case formatCSV:
+		aw.csv = csv.NewWriter(w)
+	case formatTSV:
+		aw.csv = csv.NewWriter(w)
+		aw.csv.Comma = '\t'
+	}
+	return aw
+}
+
+// Write outputs a single artifact. info and path are optional.
+func (aw *artifactWriter) Write(a Artifact, info *nexus.ArtifactInfo, path string) error {
+	switch aw.format {
+	case formatText:
+		if info != nil {
+			printInfo(aw.w, info)
+			return nil
+		}
+		_, err := fmt.Fprintln(aw.w, a)
+		return err
+	case formatJSON:
+		aw.records = append(aw.records, newArtifactRecord(a, info, path))
+		return nil
+	case formatJSONL:
+		return json.NewEncoder(aw.w).Encode(newArtifactRecord(a, info, path))
+	case formatCSV, formatTSV:
+		if err := aw.writeHeader(); err != nil {
+			return err
+		}
+		return aw.csv.Write(newArtifactRecord(a, info, path).row(aw.withInfo))
+	case formatTemplate:
+		data := &templateArtifact{Artifact: a, Path: path, client: aw.client, info: info}
+		if err := aw.tmpl.Execute(aw.w, data); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(aw.w)
+		return err
+	}
+	return fmt.Errorf("unknown output format: %s", aw.format)
+}
+
+// writeHeader writes the csv/tsv header if it is not written yet.
+func (aw *artifactWriter) writeHeader() error {
+	if aw.header {
+		return nil
+	}
+	aw.header = true
+	header := artifactColumns
+	if aw.withInfo {
+		header = append(append([]string{}, artifactColumns...), infoColumns...)
+	}
+	return aw.csv.Write(header)
+}
+
+// Flush writes any buffered output, and the csv/tsv header when there were no
+// artifacts.
+func (aw *artifactWriter) Flush() error {
+	switch aw.format {
+	case formatJSON:
+		records := aw.records
+		if records == nil {
+			records = []artifactRecord{}
+		}
+		b, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(aw.w, string(b))
+		return err
+	case formatCSV, formatTSV:
+		if err := aw.writeHeader(); err != nil {
+			return err
+		}
+		aw.csv.Flush()
+		return aw.csv.Error()
+	}
+	return nil
+}
+
+// repositoryRecord is the machine readable form of a Repository.
+type repositoryRecord struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Format    string `json:"format"`
+	Policy    string `json:"policy"`
+	RemoteURI string `json:"remoteUri,omitempty"`
+}
+
+var repositoryColumns = []string{"id", "name", "type", "format", "policy", "remoteUri"}
+
+// writeRepositories writes repos in one of the output formats other than
+// template.
+func writeRepositories(w io.Writer, format string, repos []*nexus.Repository) error {
+	records := make([]repositoryRecord, len(repos))
+	for i, r := range repos {
+		records[i] = repositoryRecord{r.ID, r.Name, r.Type, r.Format, r.Policy, r.RemoteURI}
+	}
+	switch format {
+	case formatText:
+		for _, r := range repos {
+			if _, err := fmt.Fprintln(w, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	case formatJSON:
+		b, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case formatJSONL:
+		enc := json.NewEncoder(w)
+		for _, r := range records {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	case formatCSV, formatTSV:
+		cw := csv.NewWriter(w)
+		if format == formatTSV {
+			cw.Comma = '\t'
+		}
+		cw.Write(repositoryColumns)
+		for _, r := range records {
+			cw.Write([]string{r.ID, r.Name, r.Type, r.Format, r.Policy, r.RemoteURI})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown output format: %s", format)
+}
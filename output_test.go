@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/hanjos/nexus"
+)
+
+func TestArtifactWriter(t *testing.T) {
+	a, _ := newArtifact(&nexus.Artifact{GroupID: "com.example", ArtifactID: "lib", Version: "1.0.0", Extension: "jar", RepositoryID: "releases"})
+	uploaded := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	info := &nexus.ArtifactInfo{
+		Uploader:    "deployer",
+		Uploaded:    uploaded,
+		LastChanged: uploaded,
+		Sha1:        "abc",
+		Size:        42,
+		MimeType:    "application/java-archive",
+		URL:         "http://nexus/lib-1.0.0.jar",
+	}
+
+	tests := []struct {
+		name      string
+		format    string
+		withInfo  bool
+		artifacts int
+		want      string
+	}{
+		{"csv", formatCSV, false, 1, "groupId,artifactId,version,classifier,extension,repositoryId,semver\n" +
+			"com.example,lib,1.0.0,,jar,releases,1.0.0\n"},
+		{"csv with info", formatCSV, true, 1, "groupId,artifactId,version,classifier,extension,repositoryId,semver,uploader,uploaded,lastChanged,sha1,size,mimeType,url,path\n" +
+			"com.example,lib,1.0.0,,jar,releases,1.0.0,deployer,2020-01-02T03:04:05Z,2020-01-02T03:04:05Z,abc,42,application/java-archive,http://nexus/lib-1.0.0.jar,lib.jar\n"},
+		{"empty csv", formatCSV, false, 0, "groupId,artifactId,version,classifier,extension,repositoryId,semver\n"},
+		{"tsv", formatTSV, false, 2, "groupId\tartifactId\tversion\tclassifier\textension\trepositoryId\tsemver\n" +
+			"com.example\tlib\t1.0.0\t\tjar\treleases\t1.0.0\n" +
+			"com.example\tlib\t1.0.0\t\tjar\treleases\t1.0.0\n"},
+		{"empty tsv with info", formatTSV, true, 0, "groupId\tartifactId\tversion\tclassifier\textension\trepositoryId\tsemver\tuploader\tuploaded\tlastChanged\tsha1\tsize\tmimeType\turl\tpath\n"},
+		{"json", formatJSON, false, 1, `[
+  {
+    "groupId": "com.example",
+    "artifactId": "lib",
+    "version": "1.0.0",
+    "classifier": "",
+    "extension": "jar",
+    "repositoryId": "releases",
+    "semver": "1.0.0",
+    "uploader": "deployer",
+    "uploaded": "2020-01-02T03:04:05Z",
+    "lastChanged": "2020-01-02T03:04:05Z",
+    "sha1": "abc",
+    "size": 42,
+    "mimeType": "application/java-archive",
+    "url": "http://nexus/lib-1.0.0.jar",
+    "path": "lib.jar"
+  }
+]
+`},
+		{"empty json", formatJSON, false, 0, "[]\n"},
+		{"jsonl", formatJSONL, false, 2, `{"groupId":"com.example","artifactId":"lib","version":"1.0.0","classifier":"","extension":"jar","repositoryId":"releases","semver":"1.0.0","uploader":"deployer","uploaded":"2020-01-02T03:04:05Z","lastChanged":"2020-01-02T03:04:05Z","sha1":"abc","size":42,"mimeType":"application/java-archive","url":"http://nexus/lib-1.0.0.jar","path":"lib.jar"}
+{"groupId":"com.example","artifactId":"lib","version":"1.0.0","classifier":"","extension":"jar","repositoryId":"releases","semver":"1.0.0","uploader":"deployer","uploaded":"2020-01-02T03:04:05Z","lastChanged":"2020-01-02T03:04:05Z","sha1":"abc","size":42,"mimeType":"application/java-archive","url":"http://nexus/lib-1.0.0.jar","path":"lib.jar"}
+`},
+		{"empty jsonl", formatJSONL, false, 0, ""},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		aw := newArtifactWriter(&b, tt.format, tt.withInfo)
+		for i := 0; i < tt.artifacts; i++ {
+			if err := aw.Write(a, info, "lib.jar"); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+		}
+		if err := aw.Flush(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, b.String(), tt.want)
+		}
+	}
+}
+
+func TestWriteRepositories(t *testing.T) {
+	repos := []*nexus.Repository{
+		{ID: "releases", Name: "Releases", Type: "hosted", Format: "maven2", Policy: "RELEASE"},
+		{ID: "central", Name: "Central", Type: "proxy", Format: "maven2", Policy: "RELEASE", RemoteURI: "https://repo1.maven.org/maven2/"},
+	}
+	tests := []struct {
+		name   string
+		format string
+		repos  []*nexus.Repository
+		want   string
+	}{
+		{"csv", formatCSV, repos, "id,name,type,format,policy,remoteUri\n" +
+			"releases,Releases,hosted,maven2,RELEASE,\n" +
+			"central,Central,proxy,maven2,RELEASE,https://repo1.maven.org/maven2/\n"},
+		{"empty tsv", formatTSV, nil, "id\tname\ttype\tformat\tpolicy\tremoteUri\n"},
+		{"json", formatJSON, repos[:1], `[
+  {
+    "id": "releases",
+    "name": "Releases",
+    "type": "hosted",
+    "format": "maven2",
+    "policy": "RELEASE"
+  }
+]
+`},
+		{"empty json", formatJSON, nil, "[]\n"},
+		{"jsonl", formatJSONL, repos, `{"id":"releases","name":"Releases","type":"hosted","format":"maven2","policy":"RELEASE"}
+{"id":"central","name":"Central","type":"proxy","format":"maven2","policy":"RELEASE","remoteUri":"https://repo1.maven.org/maven2/"}
+`},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := writeRepositories(&b, tt.format, tt.repos); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, b.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hanjos/nexus/util"
+	"github.com/thomasf/lg"
+)
+
+// progress reports the combined progress of one or more downloads. On a
+// terminal it renders a live bar, otherwise it logs a line periodically. A nil
+// *progress reports nothing.
+type progress struct {
+	mu       sync.Mutex
+	total    int64 // expected bytes of all started downloads, when known
+	done     int64 // bytes written, including resumed ones
+	resumed  int64 // bytes that were already on disk
+	files    int   // started downloads
+	finished int   // finished downloads
+	started  time.Time
+
+	out      *os.File
+	tty      bool
+	interval time.Duration
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+func newProgress(out *os.File) *progress {
+	p := &progress{
+		out:      out,
+		tty:      isTerminal(out),
+		interval: 10 * time.Second,
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	if p.tty {
+		p.interval = 200 * time.Millisecond
+	}
+	return p
+}
+
+// Start starts reporting in the background until Stop is called.
+func (p *progress) Start() {
+	if p == nil {
+		return
+	}
+	p.started = time.Now()
+	go func() {
+		defer close(p.stopped)
+		t := time.NewTicker(p.interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				p.report()
+			case <-p.stop:
+				p.report()
+				if p.tty {
+					fmt.Fprintln(p.out)
+				}
+				return
+			}
+		}
+	}()
+}
+
+// Stop reports the final state and stops reporting.
+func (p *progress) Stop() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	<-p.stopped
+}
+
+// begin registers a download of size bytes (0 if unknown) of which offset
+// bytes already are downloaded.
+func (p *progress) begin(size, offset int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.files++
+	p.total += size
+	p.done += offset
+	p.resumed += offset
+}
+
+// end registers a finished download.
+func (p *progress) end() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.finished++
+}
+
+// Write implements io.Writer, counting the written bytes.
+func (p *progress) Write(b []byte) (int, error) {
+	if p == nil {
+		return len(b), nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done += int64(len(b))
+	return len(b), nil
+}
+
+func (p *progress) report() {
+	p.mu.Lock()
+	total, done, resumed := p.total, p.done, p.resumed
+	files, finished := p.files, p.finished
+	p.mu.Unlock()
+
+	if files == 0 {
+		return
+	}
+
+	elapsed := time.Since(p.started).Seconds()
+	var rate float64
+	if elapsed > 0 {
+		rate = float64(done-resumed) / elapsed
+	}
+	eta := "--:--"
+	if total > 0 && rate > 0 {
+		eta = formatDuration(time.Duration(float64(total-done)/rate) * time.Second)
+	}
+
+	status := fmt.Sprintf("%d/%d files %s", finished, files, util.ByteSize(done))
+	if total > 0 {
+		pct := 100 * done / total
+		status = fmt.Sprintf("%d/%d files %s/%s %3d%%", finished, files,
+			util.ByteSize(done), util.ByteSize(total), pct)
+	}
+	status = fmt.Sprintf("%s %s/s ETA %s", status, util.ByteSize(rate), eta)
+
+	if !p.tty {
+		lg.Infoln(status)
+		return
+	}
+	const width = 30
+	filled := 0
+	if total > 0 {
+		filled = int(width * done / total)
+		if filled > width {
+			filled = width
+		}
+	}
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", width-filled)
+	fmt.Fprintf(p.out, "\r\033[K[%s] %s", bar, status)
+}
+
+// formatDuration formats d as m:ss or h:mm:ss.
+func formatDuration(d time.Duration) string {
+	s := int(d.Seconds())
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
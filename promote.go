@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hanjos/nexus/credentials"
+	"github.com/thomasf/lg"
+)
+
+type PromoteCommand struct {
+	From     string `long:"from" description:"Repository to promote from" required:"true"`
+	To       string `long:"to" description:"Repository to promote to" required:"true"`
+	Move     bool   `long:"move" description:"Delete the artifacts from the source repository afterwards"`
+	Force    bool   `long:"force" description:"Overwrite existing release artifacts in the target repository"`
+	NoVerify bool   `long:"no-verify" description:"Do not verify checksums"`
+	Yes      bool   `long:"yes" short:"y" description:"Do not ask for confirmation before deleting with --move"`
+}
+
+func (s *PromoteCommand) Execute(args []string) error {
+	ctx := rootContext
+	if len(args) < 1 {
+		return usageErrorf("missing artifact coordinates")
+	}
+	gav := args[0]
+	if strings.Contains(gav, "@") {
+		return usageErrorf("give the repository with --from, not as a @repository suffix")
+	}
+
+	creds, err := newCredentials()
+	if err != nil {
+		return err
+	}
+	n, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	// every file of the version, POMs included
+	artifacts, err := resolve(ctx, n, gav+"@"+s.From, &FilterOptions{POM: true})
+	if err != nil {
+		return err
+	}
+
+	targets := make([]deployFile, len(artifacts))
+	urls := make([]string, len(artifacts))
+	for i, v := range artifacts {
+		a := *v.Artifact
+		a.RepositoryID = s.To
+		targets[i] = deployFile{Artifact: &a}
+		urls[i], err = contentURL(n, s.To, mavenPath(&a))
+		if err != nil {
+			return err
+		}
+		if !s.Force && !strings.HasSuffix(a.Version, "SNAPSHOT") {
+			found, err := exists(ctx, urls[i], creds)
+			if err != nil {
+				return err
+			}
+			if found {
+				return fmt.Errorf("%v already exists, use --force to overwrite it", &a)
+			}
+		}
+	}
+
+	tmp, err := ioutil.TempDir("", "nexus-cli-promote")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	var prog *progress
+	if !options.Quiet {
+		prog = newProgress(os.Stderr)
+	}
+	prog.Start()
+	for i, v := range artifacts {
+		targets[i].Path = filepath.Join(tmp, fmt.Sprintf("%d-%s", i, filepath.Base(mavenPath(v.Artifact))))
+		err = s.copy(ctx, n, creds, v, targets[i], urls[i], prog)
+		if err != nil {
+			break
+		}
+		lg.Infof("promoted %v to %s", v, s.To)
+	}
+	prog.Stop()
+	if err != nil {
+		return err
+	}
+
+	out, err := newOutput(n, false)
+	if err != nil {
+		return err
+	}
+	for _, t := range targets {
+		a, err := newArtifact(t.Artifact)
+		if err != nil {
+			return err
+		}
+		if err := out.Write(a, nil, ""); err != nil {
+			return err
+		}
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+
+	if !s.Move {
+		return nil
+	}
+	if !s.Yes {
+		ok, err := confirm(fmt.Sprintf("Delete %d files from %s?", len(artifacts), s.From))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("aborted, the artifacts were copied but not removed from " + s.From)
+		}
+	}
+	for _, v := range artifacts {
+		path, err := repositoryPathOf(ctx, n, v.Artifact, creds)
+		if err != nil {
+			return err
+		}
+		if err := deleteFile(ctx, n, s.From, path, creds); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copy downloads v to target.Path and uploads it to url, verifying the
+// checksum on both ends.
+func (s *PromoteCommand) copy(ctx context.Context, n contextClient, creds credentials.Credentials, v Artifact, target deployFile, url string, prog *progress) error {
+	info, err := infoOf(ctx, n, v)
+	if err != nil {
+		return err
+	}
+	var checksums []checksum
+	if !s.NoVerify && info.Sha1 != "" {
+		checksums = append(checksums, checksum{Algorithm: "sha1", Value: info.Sha1, Source: "artifact info"})
+	}
+	if err := download(ctx, target.Path, info, creds, checksums, prog); err != nil {
+		return err
+	}
+	if err := upload(ctx, url, target, creds); err != nil {
+		return err
+	}
+	if s.NoVerify || info.Sha1 == "" {
+		return nil
+	}
+
+	// verify what the target repository ended up with by fetching it, the
+	// search index of Nexus 3 which infoOf relies on may lag behind
+	resp, err := doRequest(ctx, "GET", url, nil, 0, creds)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	h := sha1.New()
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(actual, info.Sha1) {
+		return ChecksumError{
+			URL:      url,
+			Expected: checksum{Algorithm: "sha1", Value: info.Sha1, Source: info.URL},
+			Actual:   actual,
+		}
+	}
+	return nil
+}
+
+var promoteCommand PromoteCommand
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// errNotStored is returned by credential stores which have no credentials
+// for a host.
+var errNotStored = errors.New("no stored credentials")
+
+// credentialStore keeps credentials for Nexus hosts, hosts are given as the
+// configured --host URL.
+type credentialStore interface {
+	// get returns the credentials of host, or errNotStored. The username may
+	// be given as a hint.
+	get(host, username string) (string, string, error)
+	store(host, username, password string) error
+	erase(host string) error
+}
+
+// newStore returns the configured credential store. Without a configured
+// credential helper the Secret Service is used if available, otherwise the
+// encrypted file.
+func newStore() (credentialStore, error) {
+	switch options.CredentialHelper {
+	case "":
+		if secretServiceAvailable() {
+			return secretService{}, nil
+		}
+		return newFileStore()
+	case "secret-service":
+		if !secretServiceAvailable() {
+			return nil, errors.New("the Secret Service needs secret-tool and a D-Bus session")
+		}
+		return secretService{}, nil
+	case "file":
+		return newFileStore()
+	}
+	return helper(options.CredentialHelper), nil
+}
+
+// helper is an external credential helper program. It is run with the
+// action (get, store or erase) as its argument and reads the credential as
+// key=value lines on stdin, like git credential helpers do:
+//
+//	protocol=https
+//	host=nexus.example.com
+//	path=nexus
+//	username=deployer
+//	password=secret
+//
+// For get it writes the username and password lines to stdout, an empty
+// output means it has no credentials.
+type helper string
+
+func (h helper) run(action, host string, values map[string]string) (map[string]string, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, err
+	}
+	var in bytes.Buffer
+	fmt.Fprintf(&in, "protocol=%s\nhost=%s\n", u.Scheme, u.Host)
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		fmt.Fprintf(&in, "path=%s\n", path)
+	}
+	for _, key := range []string{"username", "password"} {
+		if values[key] != "" {
+			fmt.Fprintf(&in, "%s=%s\n", key, values[key])
+		}
+	}
+	in.WriteString("\n")
+
+	cmd := exec.Command(string(h), action)
+	cmd.Stdin = &in
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("credential helper %s %s: %v", h, action, err)
+	}
+	result := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if pos := strings.Index(scanner.Text(), "="); pos > 0 {
+			result[scanner.Text()[:pos]] = scanner.Text()[pos+1:]
+		}
+	}
+	return result, nil
+}
+
+func (h helper) get(host, username string) (string, string, error) {
+	result, err := h.run("get", host, map[string]string{"username": username})
+	if err != nil {
+		return "", "", err
+	}
+	if result["password"] == "" {
+		return "", "", errNotStored
+	}
+	if result["username"] != "" {
+		username = result["username"]
+	}
+	return username, result["password"], nil
+}
+
+func (h helper) store(host, username, password string) error {
+	_, err := h.run("store", host, map[string]string{"username": username, "password": password})
+	return err
+}
+
+func (h helper) erase(host string) error {
+	_, err := h.run("erase", host, nil)
+	return err
+}
+
+func (h helper) String() string {
+	return "credential helper " + string(h)
+}
+
+// storedCredential is what the built-in stores keep per host.
+type storedCredential struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// secretService stores credentials in the Secret Service of the desktop
+// session (GNOME Keyring, KWallet...) through the secret-tool program.
+type secretService struct{}
+
+func secretServiceAvailable() bool {
+	_, err := exec.LookPath("secret-tool")
+	return err == nil && os.Getenv("DBUS_SESSION_BUS_ADDRESS") != ""
+}
+
+func (secretService) get(host, username string) (string, string, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", "nexus-cli", "host", storeKey(host)).Output()
+	if err != nil || len(out) == 0 {
+		// secret-tool exits with 1 when nothing is found
+		return "", "", errNotStored
+	}
+	var c storedCredential
+	if err := json.Unmarshal(out, &c); err != nil {
+		return "", "", fmt.Errorf("secret service: %v", err)
+	}
+	return c.Username, c.Password, nil
+}
+
+func (secretService) store(host, username, password string) error {
+	secret, err := json.Marshal(storedCredential{Username: username, Password: password})
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("secret-tool", "store", "--label=nexus-cli "+storeKey(host), "service", "nexus-cli", "host", storeKey(host))
+	cmd.Stdin = bytes.NewReader(secret)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("secret-tool store: %v", err)
+	}
+	return nil
+}
+
+func (secretService) erase(host string) error {
+	cmd := exec.Command("secret-tool", "clear", "service", "nexus-cli", "host", storeKey(host))
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("secret-tool clear: %v", err)
+	}
+	return nil
+}
+
+func (secretService) String() string {
+	return "Secret Service"
+}
+
+// fileStore stores credentials in a file encrypted with AES-GCM, the key is
+// derived from a passphrase given in $NEXUS_CLI_PASSPHRASE or on the
+// terminal.
+type fileStore struct {
+	Path       string
+	passphrase string
+}
+
+// encryptedFile is the format of the file store.
+type encryptedFile struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+const pbkdf2Iterations = 600000
+
+func newFileStore() (*fileStore, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(home, ".config")
+	}
+	return &fileStore{Path: filepath.Join(dir, "nexus-cli", "credentials")}, nil
+}
+
+func (s *fileStore) getPassphrase() (string, error) {
+	if s.passphrase != "" {
+		return s.passphrase, nil
+	}
+	s.passphrase = os.Getenv("NEXUS_CLI_PASSPHRASE")
+	if s.passphrase != "" {
+		return s.passphrase, nil
+	}
+	if !isTerminal(os.Stdin) {
+		return "", fmt.Errorf("cannot ask for the passphrase of %s, stdin is not a terminal; set $NEXUS_CLI_PASSPHRASE", s.Path)
+	}
+	fmt.Fprintf(os.Stderr, "Passphrase for %s: ", s.Path)
+	p, err := readPassword(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	if p == "" {
+		return "", errors.New("empty passphrase")
+	}
+	s.passphrase = p
+	return p, nil
+}
+
+func (s *fileStore) gcm(salt []byte) (cipher.AEAD, error) {
+	passphrase, err := s.getPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	key := pbkdf2.Key([]byte(passphrase), salt, pbkdf2Iterations, 32, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// load returns the stored credentials, empty if the file does not exist.
+func (s *fileStore) load() (map[string]storedCredential, error) {
+	creds := make(map[string]storedCredential)
+	data, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return creds, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f encryptedFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %v", s.Path, err)
+	}
+	gcm, err := s.gcm(f.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt %s, wrong passphrase?", s.Path)
+	}
+	if err := json.Unmarshal(plain, &creds); err != nil {
+		return nil, fmt.Errorf("%s: %v", s.Path, err)
+	}
+	return creds, nil
+}
+
+func (s *fileStore) save(creds map[string]storedCredential) error {
+	plain, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	f := encryptedFile{Salt: make([]byte, 16)}
+	if _, err := rand.Read(f.Salt); err != nil {
+		return err
+	}
+	gcm, err := s.gcm(f.Salt)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return err
+	}
+	f.Data = gcm.Seal(nil, f.Nonce, plain, nil)
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return err
+	}
+	tmp := s.Path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Path)
+}
+
+func (s *fileStore) get(host, username string) (string, string, error) {
+	if _, err := os.Stat(s.Path); os.IsNotExist(err) {
+		return "", "", errNotStored
+	}
+	creds, err := s.load()
+	if err != nil {
+		return "", "", err
+	}
+	c, ok := creds[storeKey(host)]
+	if !ok {
+		return "", "", errNotStored
+	}
+	return c.Username, c.Password, nil
+}
+
+func (s *fileStore) store(host, username, password string) error {
+	creds, err := s.load()
+	if err != nil {
+		return err
+	}
+	creds[storeKey(host)] = storedCredential{Username: username, Password: password}
+	return s.save(creds)
+}
+
+func (s *fileStore) erase(host string) error {
+	if _, err := os.Stat(s.Path); os.IsNotExist(err) {
+		return nil
+	}
+	creds, err := s.load()
+	if err != nil {
+		return err
+	}
+	delete(creds, storeKey(host))
+	return s.save(creds)
+}
+
+func (s *fileStore) String() string {
+	return "encrypted file " + s.Path
+}
+
+// storeKey returns the key credentials are stored under by the built-in
+// stores.
+func storeKey(host string) string {
+	return strings.TrimRight(host, "/")
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	t.Setenv("NEXUS_CLI_PASSPHRASE", "correct horse")
+	path := filepath.Join(t.TempDir(), "nexus-cli", "credentials")
+	s := &fileStore{Path: path}
+
+	if _, _, err := s.get("https://nexus.example.com", ""); err != errNotStored {
+		t.Fatalf("get from a missing file: %v, want errNotStored", err)
+	}
+	if err := s.store("https://nexus.example.com/", "deployer", "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+	// a new store has to derive the key again
+	username, password, err := (&fileStore{Path: path}).get("https://nexus.example.com", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "deployer" || password != "s3cret" {
+		t.Errorf("got %q/%q, want deployer/s3cret", username, password)
+	}
+
+	if _, _, err := (&fileStore{Path: path, passphrase: "wrong"}).get("https://nexus.example.com", ""); err == nil || err == errNotStored {
+		t.Errorf("get with a wrong passphrase: %v, want a decryption error", err)
+	}
+
+	if err := s.erase("https://nexus.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.get("https://nexus.example.com", ""); err != errNotStored {
+		t.Errorf("get after erase: %v, want errNotStored", err)
+	}
+}
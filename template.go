@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sync"
+	"text/template"
+
+	"github.com/hanjos/nexus"
+	version "github.com/hashicorp/go-version"
+)
+
+// templateArtifact is the data a --template is executed against. The
+// ArtifactInfo is only fetched if the template asks for it.
+type templateArtifact struct {
+	Artifact
+	Path string // local path, set by get
+
+	client contextClient
+	info   *nexus.ArtifactInfo
+}
+
+// Info returns the ArtifactInfo of the artifact. Templates are executed
+// outside of a command's requests, so it is fetched with rootContext.
+func (t *templateArtifact) Info() (*nexus.ArtifactInfo, error) {
+	if t.info != nil {
+		return t.info, nil
+	}
+	info, err := infoOf(rootContext, t.client, t.Artifact)
+	if err != nil {
+		return nil, err
+	}
+	t.info = info
+	return info, nil
+}
+
+// newTemplate parses a --template string, n is used by the repository
+// function.
+func newTemplate(text string, n contextClient) (*template.Template, error) {
+	repos := &repositoryCache{client: n}
+	return template.New("template").Funcs(template.FuncMap{
+		"major":      func(v string) (int, error) { return segment(v, 0) },
+		"minor":      func(v string) (int, error) { return segment(v, 1) },
+		"patch":      func(v string) (int, error) { return segment(v, 2) },
+		"repository": repos.get,
+	}).Parse(text)
+}
+
+// segment returns the i:th segment of a semver version string.
+func segment(v string, i int) (int, error) {
+	ver, err := version.NewVersion(v)
+	if err != nil {
+		return 0, err
+	}
+	return ver.Segments()[i], nil
+}
+
+// repositoryCache looks up repositories by ID, fetching them once.
+type repositoryCache struct {
+	client contextClient
+
+	once  sync.Once
+	repos map[string]*nexus.Repository
+	err   error
+}
+
+func (c *repositoryCache) get(id string) (*nexus.Repository, error) {
+	c.once.Do(func() {
+		repos, err := c.client.RepositoriesContext(rootContext)
+		if err != nil {
+			c.err = err
+			return
+		}
+		c.repos = make(map[string]*nexus.Repository, len(repos))
+		for _, v := range repos {
+			c.repos[v.ID] = v
+		}
+	})
+	if c.err != nil {
+		return nil, c.err
+	}
+	if r, ok := c.repos[id]; ok {
+		return r, nil
+	}
+	return &nexus.Repository{ID: id}, nil
+}
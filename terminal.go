@@ -0,0 +1,22 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/term"
+)
+
+// isTerminal returns true if f is a terminal.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// readPassword reads a line from the terminal f without echoing it.
+func readPassword(f *os.File) (string, error) {
+	p, err := term.ReadPassword(int(f.Fd()))
+	os.Stderr.WriteString("\n")
+	if err != nil {
+		return "", err
+	}
+	return string(p), nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hanjos/nexus/credentials"
+	"github.com/hanjos/nexus/util"
+)
+
+// account is what the server tells about the caller. User is empty and
+// Deploy nil when the server does not tell.
+type account struct {
+	User    string   `json:"user,omitempty"`
+	Host    string   `json:"host"`
+	Version string   `json:"version"`
+	Edition string   `json:"edition"`
+	Read    []string `json:"read"`
+	Deploy  []string `json:"deploy"`
+}
+
+// permissionCreate is the Nexus 2 permission bit for creating, as reported
+// in the clientPermissions of the status resource.
+const permissionCreate = 8
+
+// accountOf asks the server who creds belong to, which repositories they can
+// read and, as far as it tells, deploy to. It fails with a credentials.Error for invalid
+// credentials.
+func accountOf(ctx context.Context, n contextClient, creds credentials.Credentials) (*account, error) {
+	a := &account{Host: options.Host, Read: []string{}}
+	switch n := n.(type) {
+	case *Nexus2x:
+		url, err := util.BuildFullURL(n.URL, "service/local/status", map[string]string{"perms": "1"})
+		if err != nil {
+			return nil, err
+		}
+		resp, err := doRequest(ctx, "GET", url, nil, 0, creds)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		var status struct {
+			Version     string `xml:"data>version"`
+			Edition     string `xml:"data>editionShort"`
+			LoggedIn    bool   `xml:"data>clientPermissions>loggedIn"`
+			Username    string `xml:"data>clientPermissions>loggedInUsername"`
+			Permissions []struct {
+				ID    string `xml:"id"`
+				Value int    `xml:"value"`
+			} `xml:"data>clientPermissions>permissions>permission"`
+		}
+		if err := xml.NewDecoder(resp.Body).Decode(&status); err != nil {
+			return nil, err
+		}
+		a.Version, a.Edition = status.Version, status.Edition
+		a.User = "anonymous"
+		if status.LoggedIn && status.Username != "" {
+			a.User = status.Username
+		}
+		// repository target privileges are not visible to the user, only
+		// whether artifacts may be uploaded at all. Without that there is
+		// nowhere to deploy to, with it the repositories are unknown.
+		for _, p := range status.Permissions {
+			if p.ID == "nexus:artifact" && p.Value&permissionCreate == 0 {
+				a.Deploy = []string{}
+			}
+		}
+	case *Nexus3x:
+		url, err := util.BuildFullURL(n.URL, "service/rest/v1/status", nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := doRequest(ctx, "GET", url, nil, 0, creds)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+		// e.g. Nexus/3.61.0-02 (OSS)
+		for _, server := range resp.Header["Server"] {
+			if !strings.HasPrefix(server, "Nexus/") {
+				continue
+			}
+			a.Version = strings.TrimPrefix(server, "Nexus/")
+			if pos := strings.Index(a.Version, " ("); pos != -1 {
+				a.Edition = strings.TrimSuffix(a.Version[pos+2:], ")")
+				a.Version = a.Version[:pos]
+			}
+		}
+		// Nexus 3 has no public resource for the current user or its
+		// privileges, invalid credentials have been rejected by now though
+	default:
+		return nil, errors.New("unsupported nexus client")
+	}
+
+	// the servers only list the repositories the user may browse
+	repos, err := n.RepositoriesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := n.(*Nexus2x); ok {
+		groups, err := fetchGroups(ctx, options.Host, creds)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, groups...)
+	}
+	sort.Sort(ByID(repos))
+	for _, r := range repos {
+		a.Read = append(a.Read, r.ID)
+	}
+	return a, nil
+}
+
+func (a *account) write(w io.Writer) error {
+	if options.Format == formatJSON || options.Format == formatJSONL {
+		return json.NewEncoder(w).Encode(a)
+	}
+	version := strings.TrimSpace("Nexus " + a.Version + " " + a.Edition)
+	list := func(ids []string) string {
+		if ids == nil {
+			return "unknown"
+		}
+		if len(ids) == 0 {
+			return "-"
+		}
+		return strings.Join(ids, ", ")
+	}
+	user := a.User
+	if user == "" {
+		user = "unknown"
+	}
+	_, err := fmt.Fprintf(w, "user:    %s\nserver:  %s (%s)\nread:    %s\ndeploy:  %s\n",
+		user, a.Host, version, list(a.Read), list(a.Deploy))
+	return err
+}
+
+type WhoamiCommand struct{}
+
+func (s *WhoamiCommand) Execute(args []string) error {
+	ctx := rootContext
+	creds, err := newCredentials()
+	if err != nil {
+		return err
+	}
+	n, err := clientFor(ctx, creds)
+	if err != nil {
+		return err
+	}
+	a, err := accountOf(ctx, n, creds)
+	if err != nil {
+		return err
+	}
+	return a.write(os.Stdout)
+}
+
+var whoamiCommand WhoamiCommand